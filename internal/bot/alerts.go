@@ -0,0 +1,276 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+var alertKindLabels = map[string]string{
+	"above": "⬆️ عبور به بالا",
+	"below": "⬇️ عبور به پایین",
+	"move":  "📈 تغییر درصدی در بازه",
+}
+
+// handleAlertCallback serves the alert-rules menus. Callback data always
+// carries the chat ID in parts[1].
+func (a *App) handleAlertCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 2 {
+		return
+	}
+	chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+	var ruleID int64
+	if len(parts) > 2 {
+		ruleID, _ = strconv.ParseInt(parts[2], 10, 64)
+	}
+
+	switch parts[0] {
+	case "alerts":
+		a.sendAlertsMenu(userID, msgID, chatID)
+	case "aladd":
+		a.sendAlertItemMenu(userID, msgID, chatID)
+	case "alitem":
+		// alitem|chatID|itemID
+		if len(parts) < 3 { return }
+		a.sendAlertKindMenu(userID, msgID, chatID, parts[2])
+	case "alkind":
+		// alkind|chatID|itemID|kind
+		if len(parts) < 4 { return }
+		s := a.ensureSession(userID)
+		s.SelectedChatID = chatID
+		s.AlertItemID = parts[2]
+		s.AlertKind = parts[3]
+		s.Await = AwaitAlertLevel
+		prompt := "قیمت سطح هشدار را بفرستید (مثلاً 70000 یا ۷۰٬۰۰۰)."
+		if parts[3] == "move" {
+			prompt = "درصد تغییر را بفرستید (مثلاً 2 یعنی ۲٪ در ۶۰ دقیقه؛ بازه را بعداً می‌توانید تغییر دهید)."
+		}
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, prompt))
+	case "alrule":
+		a.sendAlertRuleMenu(userID, msgID, chatID, ruleID)
+	case "alon", "alpin":
+		r, err := a.db.GetAlertRule(ctx, ruleID)
+		if err != nil || r.ChatID != chatID { return }
+		if parts[0] == "alon" {
+			_ = a.db.UpdateAlertRule(ctx, ruleID, "enabled", !r.Enabled)
+		} else {
+			_ = a.db.UpdateAlertRule(ctx, ruleID, "pin", !r.Pin)
+		}
+		a.sendAlertRuleMenu(userID, msgID, chatID, ruleID)
+	case "alcd", "alwin":
+		// alcd|chatID|ruleID|deltaMinutes
+		if len(parts) < 4 { return }
+		r, err := a.db.GetAlertRule(ctx, ruleID)
+		if err != nil || r.ChatID != chatID { return }
+		delta, _ := strconv.Atoi(parts[3])
+		key, cur, floor := "cooldown_minutes", r.CooldownMinutes, 0
+		if parts[0] == "alwin" {
+			key, cur, floor = "window_minutes", r.WindowMinutes, 5
+		}
+		cur += delta
+		if cur < floor { cur = floor }
+		_ = a.db.UpdateAlertRule(ctx, ruleID, key, cur)
+		a.sendAlertRuleMenu(userID, msgID, chatID, ruleID)
+	case "altmpl":
+		r, err := a.db.GetAlertRule(ctx, ruleID)
+		if err != nil || r.ChatID != chatID { return }
+		s := a.ensureSession(userID)
+		s.SelectedChatID = chatID
+		s.AlertRuleID = ruleID
+		s.Await = AwaitAlertTemplate
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "متن هشدار را بفرستید.\n\nجایگزین‌ها: {EMOJI} {NAME} {PRICE} {LEVEL} {CHANGE} {WINDOW} {DATETIME}\n\nمتن پیش‌فرض:\n"+render.DefaultAlertTemplate(r.Kind)))
+	case "altmplrst":
+		r, err := a.db.GetAlertRule(ctx, ruleID)
+		if err != nil || r.ChatID != chatID { return }
+		_ = a.db.UpdateAlertRule(ctx, ruleID, "template", "")
+		a.sendAlertRuleMenu(userID, msgID, chatID, ruleID)
+	case "aldel":
+		r, err := a.db.GetAlertRule(ctx, ruleID)
+		if err != nil || r.ChatID != chatID { return }
+		_ = a.db.DeleteAlertRule(ctx, ruleID)
+		a.sendAlertsMenu(userID, msgID, chatID)
+	}
+}
+
+func alertRuleLabel(r db.AlertRule) string {
+	name := r.ItemID
+	if it, ok := items.ByID(r.ItemID); ok {
+		name = it.Emoji + " " + it.NameFa
+	}
+	var cond string
+	switch r.Kind {
+	case "above":
+		cond = "≥ " + utils.FormatNumber(r.Level, "", "en")
+	case "below":
+		cond = "≤ " + utils.FormatNumber(r.Level, "", "en")
+	case "move":
+		cond = fmt.Sprintf("±%s%% / %dm", strconv.FormatFloat(r.Level, 'f', -1, 64), r.WindowMinutes)
+	}
+	mark := "🔔"
+	if !r.Enabled {
+		mark = "🔕"
+	}
+	return fmt.Sprintf("%s %s %s", mark, truncate(name, 18), cond)
+}
+
+func (a *App) sendAlertsMenu(userID int64, msgID int, chatID int64) {
+	ctx := context.Background()
+	rules, _ := a.db.ListAlertRules(ctx, chatID)
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, r := range rules {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(alertRuleLabel(r), fmt.Sprintf("alrule|%d|%d", chatID, r.RuleID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ هشدار جدید", fmt.Sprintf("aladd|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", chatID)),
+		),
+	)
+	text := fmt.Sprintf("🚨 هشدارهای قیمت\n\nهشدارها جدا از پیام اصلی (بورد) و هر دقیقه بررسی می‌شوند.\nتعداد: %d", len(rules))
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) sendAlertItemMenu(userID int64, msgID int, chatID int64) {
+	ctx := context.Background()
+	ids, _ := a.db.EnabledItemIDs(ctx, chatID)
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	row := []tgbotapi.InlineKeyboardButton{}
+	for _, id := range ids {
+		it, ok := items.ByID(id)
+		if !ok { continue }
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(it.Emoji+" "+truncate(it.NameFa, 14), fmt.Sprintf("alitem|%d|%s", chatID, id)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = []tgbotapi.InlineKeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("alerts|%d", chatID)),
+	))
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	a.editOrSendMenu(userID, msgID, "🚨 هشدار جدید\n\nآیتم را انتخاب کنید (از اقلام فعال این چت):", kb)
+}
+
+func (a *App) sendAlertKindMenu(userID int64, msgID int, chatID int64, itemID string) {
+	it, ok := items.ByID(itemID)
+	if !ok {
+		return
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, k := range []string{"above", "below", "move"} {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(alertKindLabels[k], fmt.Sprintf("alkind|%d|%s|%s", chatID, itemID, k)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("aladd|%d", chatID)),
+	))
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	a.editOrSendMenu(userID, msgID, fmt.Sprintf("🚨 هشدار برای %s %s\n\nنوع هشدار:", it.Emoji, it.NameFa), kb)
+}
+
+func (a *App) sendAlertRuleMenu(userID int64, msgID int, chatID int64, ruleID int64) {
+	ctx := context.Background()
+	r, err := a.db.GetAlertRule(ctx, ruleID)
+	if err != nil || r.ChatID != chatID {
+		return
+	}
+	tmpl := "پیش‌فرض"
+	if strings.TrimSpace(r.Template) != "" {
+		tmpl = "سفارشی"
+	}
+	text := fmt.Sprintf("🚨 هشدار\n\n%s\nنوع: %s\nفعال: %v\nپین: %v\nCooldown: %s\nمتن: %s",
+		alertRuleLabel(r), alertKindLabels[r.Kind], r.Enabled, r.Pin, minutesOrOff(r.CooldownMinutes), tmpl)
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔔 روشن/خاموش", fmt.Sprintf("alon|%d|%d", chatID, ruleID)),
+			tgbotapi.NewInlineKeyboardButtonData("📌 پین", fmt.Sprintf("alpin|%d|%d", chatID, ruleID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Cooldown -15m", fmt.Sprintf("alcd|%d|%d|-15", chatID, ruleID)),
+			tgbotapi.NewInlineKeyboardButtonData("Cooldown +15m", fmt.Sprintf("alcd|%d|%d|15", chatID, ruleID)),
+		),
+	}
+	if r.Kind == "move" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("بازه -15m", fmt.Sprintf("alwin|%d|%d|-15", chatID, ruleID)),
+			tgbotapi.NewInlineKeyboardButtonData("بازه +15m", fmt.Sprintf("alwin|%d|%d|15", chatID, ruleID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ متن هشدار", fmt.Sprintf("altmpl|%d|%d", chatID, ruleID)),
+			tgbotapi.NewInlineKeyboardButtonData("↩️ متن پیش‌فرض", fmt.Sprintf("altmplrst|%d|%d", chatID, ruleID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 حذف", fmt.Sprintf("aldel|%d|%d", chatID, ruleID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("alerts|%d", chatID)),
+		),
+	)
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) onAlertLevelMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	level, ok := utils.ParseNumber(msg.Text)
+	if !ok || level <= 0 {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "عدد معتبر نیست. لطفاً فقط عدد بفرستید."))
+		return
+	}
+	chatID := sess.SelectedChatID
+	rule := db.AlertRule{
+		ChatID:          chatID,
+		ItemID:          sess.AlertItemID,
+		Kind:            sess.AlertKind,
+		Level:           level,
+		WindowMinutes:   60,
+		CooldownMinutes: 60,
+	}
+	a.clearAwait(userID)
+	ruleID, err := a.db.CreateAlertRule(ctx, rule)
+	if err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ساخت هشدار ناموفق: "+err.Error()))
+		return
+	}
+	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ هشدار ساخته شد."))
+	a.sendAlertRuleMenu(userID, 0, chatID, ruleID)
+}
+
+func (a *App) onAlertTemplateMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	body := strings.TrimSpace(msg.Text)
+	if body == "" {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "متن خالی است. لطفاً متن را بفرستید."))
+		return
+	}
+	chatID, ruleID := sess.SelectedChatID, sess.AlertRuleID
+	a.clearAwait(userID)
+	if err := a.db.UpdateAlertRule(ctx, ruleID, "template", body); err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ذخیره ناموفق: "+err.Error()))
+		return
+	}
+	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ متن هشدار ذخیره شد."))
+	a.sendAlertRuleMenu(userID, 0, chatID, ruleID)
+}
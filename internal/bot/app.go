@@ -19,6 +19,8 @@ import (
 	"github.com/Armin-kho/persian-currency-bot/internal/config"
 	"github.com/Armin-kho/persian-currency-bot/internal/db"
 	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/leader"
+	"github.com/Armin-kho/persian-currency-bot/internal/outbox"
 	"github.com/Armin-kho/persian-currency-bot/internal/render"
 	"github.com/Armin-kho/persian-currency-bot/internal/scheduler"
 	"github.com/Armin-kho/persian-currency-bot/internal/sources"
@@ -40,9 +42,21 @@ const (
 	AwaitAddTemplateName Awaiting = "add_template_name"
 	AwaitAddTemplateBody Awaiting = "add_template_body"
 	AwaitEditTemplateBody Awaiting = "edit_template_body"
+	AwaitRenameTemplate   Awaiting = "rename_template"
 	AwaitSetTemplateMedia Awaiting = "set_template_media"
 
 	AwaitRestoreDB Awaiting = "restore_db"
+
+	AwaitAlertLevel    Awaiting = "alert_level"
+	AwaitAlertTemplate Awaiting = "alert_template"
+
+	AwaitDigestTemplate Awaiting = "digest_template"
+
+	AwaitUserAlertLevel Awaiting = "user_alert_level"
+
+	AwaitApprovalAllowlist Awaiting = "approval_allowlist"
+
+	AwaitProfileName Awaiting = "profile_name"
 )
 
 type Session struct {
@@ -53,6 +67,25 @@ type Session struct {
 
 	TemplateID string
 	TempName   string
+
+	// Alert rule flow
+	AlertItemID string
+	AlertKind   string
+	AlertRuleID int64
+
+	// Digest flow
+	DigestPeriod string
+
+	// ProfileSel holds the chats picked for a bulk profile apply.
+	ProfileSel map[int64]bool
+	// CloneSel holds the sections picked in the "copy settings from" flow.
+	CloneSel map[string]bool
+	// ImportData is a validated settings file waiting for confirmation.
+	ImportData []byte
+	// MediaMode (single, album or rotate) and MediaItems hold the files
+	// collected so far for a template's media.
+	MediaMode  string
+	MediaItems []db.MediaItem
 }
 
 type App struct {
@@ -60,13 +93,30 @@ type App struct {
 	db  *db.DB
 
 	bot *tgbotapi.BotAPI
+	// out paces background sends (scheduled posts, admin DMs) under Telegram's flood limits.
+	out *outbox.Outbox
 
 	sources *sources.Manager
 	sched   *scheduler.Scheduler
 
+	// leader holds the single-instance lease; without it we stay in standby.
+	leader *leader.Elector
+
 	sessMu sync.Mutex
 	sess   map[int64]*Session // by user id
 
+	// lastCmd rate-limits group command replies per chat.
+	cmdMu   sync.Mutex
+	lastCmd map[int64]time.Time
+
+	// inlineCache holds inline query results per normalized query.
+	inlineMu    sync.Mutex
+	inlineCache map[string]inlineCacheEntry
+
+	// ownerAdmins caches getChatAdministrators per chat for self-service menus.
+	ownerMu     sync.Mutex
+	ownerAdmins map[int64]ownerAdminsEntry
+
 	// Data dir
 	dataDir string
 	dbPath  string
@@ -100,14 +150,21 @@ func New(cfg config.Config) (*App, error) {
 		cfg: cfg,
 		db: database,
 		bot: b,
+		out: outbox.New(b),
 		sources: sources.NewManager(database),
 		sess: map[int64]*Session{},
+		lastCmd: map[int64]time.Time{},
+		inlineCache: map[string]inlineCacheEntry{},
+		ownerAdmins: map[int64]ownerAdminsEntry{},
 		dataDir: dataDir,
 		dbPath: dbPath,
 	}
 
+	app.leader = leader.New(database, app.onLeadershipChange)
+
 	// Scheduler
-	app.sched = scheduler.New(database, app.sources, b, app)
+	app.sched = scheduler.New(database, app.sources, app.out, app)
+	app.sched.SetLeaderCheck(app.leader.IsLeader)
 	return app, nil
 }
 
@@ -115,25 +172,60 @@ func (a *App) Close() {
 	if a.sched != nil {
 		a.sched.Stop()
 	}
+	a.leader.Stop()
+	a.out.Close()
 	_ = a.db.Close()
 }
 
 func (a *App) Run() error {
 	log.Printf("Bot authorized as @%s", a.bot.Self.UserName)
 
+	a.leader.Start()
 	a.sched.Start()
 
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 30
 	// Receive chat member updates for approvals
-	u.AllowedUpdates = []string{"message", "callback_query", "my_chat_member", "chat_member"}
+	u.AllowedUpdates = []string{"message", "callback_query", "my_chat_member", "chat_member", "inline_query"}
+
+	// Poll by hand instead of GetUpdatesChan so polling can pause while
+	// another instance holds the lease (and resume after a takeover).
+	standby := false
+	for {
+		if !a.leader.IsLeader() {
+			if !standby {
+				log.Printf("Standby: another instance holds the database lease; not polling or posting")
+				standby = true
+			}
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		standby = false
 
-	updates := a.bot.GetUpdatesChan(u)
+		updates, err := a.bot.GetUpdates(u)
+		if err != nil {
+			log.Printf("get updates: %v (retrying in 3s)", err)
+			time.Sleep(3 * time.Second)
+			continue
+		}
+		for _, upd := range updates {
+			if upd.UpdateID >= u.Offset {
+				u.Offset = upd.UpdateID + 1
+				a.handleUpdate(upd)
+			}
+		}
+	}
+}
 
-	for upd := range updates {
-		a.handleUpdate(upd)
+// onLeadershipChange tells admins when this instance took the lease over
+// from another running (or crashed) instance.
+func (a *App) onLeadershipChange(isLeader bool, prev db.Lease) {
+	if !isLeader || prev.Holder == "" || prev.Holder == a.leader.Holder() {
+		return
 	}
-	return nil
+	text := fmt.Sprintf("🔁 این نمونه از ربات کنترل را به دست گرفت.\n\nنمونه جدید: %s\nنمونه قبلی: %s\nآخرین heartbeat قبلی: %s\n\nاگر دو نسخه از ربات همزمان اجرا می‌شوند (مثلاً systemd و docker)، یکی را متوقف کنید.",
+		a.leader.Holder(), prev.Holder, time.Unix(prev.HeartbeatAt, 0).In(utils.TehranLoc()).Format(time.RFC3339))
+	go a.NotifyAdmins(context.Background(), text, nil)
 }
 
 // Notifier interface for scheduler
@@ -143,11 +235,14 @@ func (a *App) NotifyAdmins(ctx context.Context, text string, kb *tgbotapi.Inline
 		return
 	}
 	for _, ad := range admins {
+		if ad.Role == db.RoleManager {
+			continue
+		}
 		msg := tgbotapi.NewMessage(ad.UserID, text)
 		if kb != nil {
 			msg.ReplyMarkup = kb
 		}
-		_, _ = a.bot.Send(msg)
+		_, _ = a.out.Send(ctx, ad.UserID, msg)
 	}
 }
 
@@ -164,6 +259,10 @@ func (a *App) handleUpdate(upd tgbotapi.Update) {
 		a.handleCallback(*upd.CallbackQuery)
 		return
 	}
+	if upd.InlineQuery != nil {
+		go a.handleInlineQuery(*upd.InlineQuery)
+		return
+	}
 }
 
 func (a *App) ensureSession(userID int64) *Session {
@@ -184,6 +283,12 @@ func (a *App) clearAwait(userID int64) {
 		s.Await = AwaitNone
 		s.TemplateID = ""
 		s.TempName = ""
+		s.AlertItemID = ""
+		s.AlertKind = ""
+		s.AlertRuleID = 0
+		s.DigestPeriod = ""
+		s.MediaMode = ""
+		s.MediaItems = nil
 	}
 }
 
@@ -197,6 +302,7 @@ func (a *App) handleMyChatMember(m tgbotapi.ChatMemberUpdated) {
 
 	added := (oldStatus == "left" || oldStatus == "kicked") && (newStatus == "member" || newStatus == "administrator")
 	if !added {
+		a.onBotStatusChange(m)
 		return
 	}
 
@@ -208,6 +314,12 @@ func (a *App) handleMyChatMember(m tgbotapi.ChatMemberUpdated) {
 	typ := chat.Type
 
 	_ = a.db.UpsertChat(context.Background(), chat.ID, title, typ)
+	_ = a.db.SetChatProblem(context.Background(), chat.ID, botProblem(typ, m.NewChatMember))
+
+	if a.applyApprovalPolicy(m) {
+		return
+	}
+	_ = a.db.MarkChatRequested(context.Background(), chat.ID)
 
 	// Try to notify in the chat itself
 	chatMsg := "✅ ربات اضافه شد.\n\n⏳ این چت هنوز تایید نشده.\nادمین ربات در پیام خصوصی می‌تونه تایید/رد کنه.\n\n(پیکربندی فقط از طریق چت خصوصی با ربات انجام می‌شود.)"
@@ -242,9 +354,12 @@ func (a *App) handleMyChatMember(m tgbotapi.ChatMemberUpdated) {
 	)
 
 	for _, ad := range admins {
+		if ad.Role == db.RoleManager {
+			continue
+		}
 		msg := tgbotapi.NewMessage(ad.UserID, text)
 		msg.ReplyMarkup = kb
-		_, _ = a.bot.Send(msg)
+		_, _ = a.out.Send(ctx, ad.UserID, msg)
 	}
 }
 
@@ -254,6 +369,16 @@ func (a *App) handleMessage(msg tgbotapi.Message) {
 		return
 	}
 	if msg.Chat.Type != "private" {
+		// Group upgraded to a supergroup: the old group gets migrate_to, the
+		// new supergroup migrate_from. Whichever arrives first moves the chat.
+		if msg.MigrateToChatID != 0 {
+			a.migrateChat(msg.Chat.ID, msg.MigrateToChatID)
+			return
+		}
+		if msg.MigrateFromChatID != 0 {
+			a.migrateChat(msg.MigrateFromChatID, msg.Chat.ID)
+			return
+		}
 		// Detect bot being added via message.new_chat_members too (some clients)
 		if len(msg.NewChatMembers) > 0 {
 			for _, u := range msg.NewChatMembers {
@@ -262,13 +387,18 @@ func (a *App) handleMessage(msg tgbotapi.Message) {
 				}
 			}
 		}
+		if msg.IsCommand() {
+			go a.handleGroupCommand(msg)
+		} else if msg.Text != "" {
+			go a.handleGroupConversion(msg)
+		}
 		return
 	}
 
 	userID := int64(msg.From.ID)
 
 	// If no admins exist, first user becomes super admin (as requested).
-	ctx := context.Background()
+	ctx := db.WithActor(context.Background(), userID)
 	adminCount, err := a.db.AdminCount(ctx)
 	if err == nil && adminCount == 0 {
 		_ = a.db.AddAdmin(ctx, userID, true)
@@ -276,13 +406,20 @@ func (a *App) handleMessage(msg tgbotapi.Message) {
 	}
 
 	isAdmin, isSuper, _ := a.db.IsAdmin(ctx, userID)
+	sess := a.ensureSession(userID)
+
 	if !isAdmin {
-		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "⛔️ دسترسی ندارید. لطفاً از ادمین اصلی بخواهید شما را به لیست ادمین‌ها اضافه کند."))
+		if sess.Await == AwaitUserAlertLevel {
+			a.onUserAlertLevelMessage(ctx, msg, sess)
+			return
+		}
+		if a.replyPrivateConversion(ctx, msg) {
+			return
+		}
+		a.sendUserMenu(userID, 0)
 		return
 	}
 
-	sess := a.ensureSession(userID)
-
 	// Awaiting flows
 	switch sess.Await {
 	case AwaitAddAdmin:
@@ -307,20 +444,7 @@ func (a *App) handleMessage(msg tgbotapi.Message) {
 		a.sendGlobalSourceMenu(userID, msg.MessageID)
 		return
 	case AwaitImportSettings:
-		// Import settings JSON for selected chat
-		if sess.SelectedChatID == 0 {
-			a.clearAwait(userID)
-			return
-		}
-		data := []byte(msg.Text)
-		err := a.db.ImportChatSettings(ctx, sess.SelectedChatID, data)
-		a.clearAwait(userID)
-		if err != nil {
-			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ خطا در Import: "+err.Error()))
-		} else {
-			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ تنظیمات وارد شد."))
-		}
-		a.sendChatMenu(userID, msg.MessageID, sess.SelectedChatID)
+		a.onImportSettingsMessage(ctx, msg, sess)
 		return
 	case AwaitAddTemplateName:
 		sess.TempName = strings.TrimSpace(msg.Text)
@@ -358,50 +482,39 @@ func (a *App) handleMessage(msg tgbotapi.Message) {
 			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "متن قالب خالی است. لطفاً متن را بفرستید."))
 			return
 		}
-		err := a.db.UpdateTemplateBody(ctx, sess.TemplateID, body)
+		tid := sess.TemplateID
+		err := a.db.UpdateTemplateBody(ctx, tid, body)
 		a.clearAwait(userID)
 		if err != nil {
 			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ویرایش ناموفق: "+err.Error()))
 		} else {
 			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ متن قالب ذخیره شد."))
 		}
-		a.sendTemplatesMenu(userID, msg.MessageID, sess.SelectedChatID)
+		a.sendTemplateManageMenu(userID, msg.MessageID, sess.SelectedChatID, tid, "")
+		return
+	case AwaitRenameTemplate:
+		a.onTemplateRenameMessage(ctx, msg, sess)
 		return
 	case AwaitSetTemplateMedia:
-		if sess.TemplateID == "" {
-			a.clearAwait(userID)
-			return
-		}
-		mediaType := ""
-		fileID := ""
-		if len(msg.Photo) > 0 {
-			ph := msg.Photo[len(msg.Photo)-1]
-			mediaType = "photo"
-			fileID = ph.FileID
-		} else if msg.Video != nil {
-			mediaType = "video"
-			fileID = msg.Video.FileID
-		} else if msg.Document != nil {
-			// allow sending as file (we'll treat as photo if it's an image)
-			mediaType = "document"
-			fileID = msg.Document.FileID
-		}
-		if fileID == "" {
-			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "لطفاً یک عکس یا ویدیو ارسال کنید."))
-			return
-		}
-		// Only photo/video supported for posting. If document, try as photo.
-		if mediaType == "document" {
-			mediaType = "photo"
-		}
-		err := a.db.SetTemplateMedia(ctx, sess.TemplateID, mediaType, fileID)
-		a.clearAwait(userID)
-		if err != nil {
-			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ذخیره مدیا ناموفق: "+err.Error()))
-		} else {
-			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ مدیا به قالب متصل شد."))
-		}
-		a.sendTemplatesMenu(userID, msg.MessageID, sess.SelectedChatID)
+		a.onTemplateMediaMessage(ctx, msg, sess)
+		return
+	case AwaitAlertLevel:
+		a.onAlertLevelMessage(ctx, msg, sess)
+		return
+	case AwaitAlertTemplate:
+		a.onAlertTemplateMessage(ctx, msg, sess)
+		return
+	case AwaitDigestTemplate:
+		a.onDigestTemplateMessage(ctx, msg, sess)
+		return
+	case AwaitUserAlertLevel:
+		a.onUserAlertLevelMessage(ctx, msg, sess)
+		return
+	case AwaitApprovalAllowlist:
+		a.onApprovalAllowlistMessage(ctx, msg)
+		return
+	case AwaitProfileName:
+		a.onProfileNameMessage(ctx, msg, sess)
 		return
 	case AwaitRestoreDB:
 		// Accept a document as DB file
@@ -420,6 +533,10 @@ func (a *App) handleMessage(msg tgbotapi.Message) {
 		return
 	}
 
+	if !msg.IsCommand() && a.replyPrivateConversion(ctx, msg) {
+		return
+	}
+
 	// Default: show main menu
 	a.sendMainMenu(userID, msg.MessageID)
 }
@@ -470,16 +587,32 @@ func (a *App) handleCallback(q tgbotapi.CallbackQuery) {
 	_, _ = a.bot.Request(cb)
 
 	userID := int64(q.From.ID)
-	ctx := context.Background()
+	ctx := db.WithActor(context.Background(), userID)
 
-	isAdmin, isSuper, _ := a.db.IsAdmin(ctx, userID)
-	if !isAdmin {
-		// ignore
+	data := q.Data
+	parts := strings.Split(data, "|")
+
+	// Personal mode is open to everyone.
+	if userCallbacks[parts[0]] {
+		a.handleUserCallback(ctx, userID, q.Message.MessageID, parts)
+		return
+	}
+	// Chat owners are checked against the chat's Telegram admins instead.
+	if ownerCallbacks[parts[0]] {
+		a.handleOwnerCallback(ctx, userID, q.Message.MessageID, parts)
 		return
 	}
 
-	data := q.Data
-	parts := strings.Split(data, "|")
+	role, _ := a.db.GetRole(ctx, userID)
+	if role == "" {
+		// ignore
+		return
+	}
+	isSuper := role == db.RoleSuper
+	if !a.allowCallback(ctx, userID, role, parts) {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "⛔️ به این بخش دسترسی ندارید."))
+		return
+	}
 	switch parts[0] {
 	case "main":
 		a.sendMainMenu(userID, q.Message.MessageID)
@@ -581,8 +714,18 @@ func (a *App) handleCallback(q tgbotapi.CallbackQuery) {
 		if len(parts) < 3 { return }
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		mode := parts[2]
+		switch mode {
+		case "new", "edit", "repost", "pin":
+		default:
+			return
+		}
 		_ = a.db.UpdateChatSetting(ctx, chatID, "post_mode", mode)
 		a.sendPostModeMenu(userID, q.Message.MessageID, chatID)
+	case "editskip":
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		st, _ := a.db.GetChatSettings(ctx, chatID)
+		_ = a.db.UpdateChatSetting(ctx, chatID, "edit_ignore_time", !st.EditIgnoreTime)
+		a.sendPostModeMenu(userID, q.Message.MessageID, chatID)
 	case "digits":
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		a.sendDigitsMenu(userID, q.Message.MessageID, chatID)
@@ -674,6 +817,78 @@ case "interval":
 	case "threshold":
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		a.sendThresholdMenu(userID, q.Message.MessageID, chatID)
+	case "tith":
+		// tith|chatID|itemID
+		if len(parts) < 3 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		a.sendItemThresholdMenu(userID, q.Message.MessageID, chatID, parts[2])
+	case "tithtype":
+		if len(parts) < 3 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		itemID := parts[2]
+		st, _ := a.db.GetChatSettings(ctx, chatID)
+		th := st.ThresholdFor(itemID)
+		if th.Type == "pct" {
+			th = db.TriggerThreshold{Type: "abs", Value: 0}
+		} else {
+			th = db.TriggerThreshold{Type: "pct", Value: 0}
+		}
+		_ = a.db.SetItemThreshold(ctx, chatID, itemID, th)
+		a.sendItemThresholdMenu(userID, q.Message.MessageID, chatID, itemID)
+	case "tithadj":
+		// tithadj|chatID|itemID|delta
+		if len(parts) < 4 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		itemID := parts[2]
+		delta, _ := strconv.ParseFloat(parts[3], 64)
+		st, _ := a.db.GetChatSettings(ctx, chatID)
+		th := st.ThresholdFor(itemID)
+		th.Value += delta
+		if th.Value < 0 { th.Value = 0 }
+		_ = a.db.SetItemThreshold(ctx, chatID, itemID, th)
+		a.sendItemThresholdMenu(userID, q.Message.MessageID, chatID, itemID)
+	case "tithreset":
+		if len(parts) < 3 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		_ = a.db.ClearItemThreshold(ctx, chatID, parts[2])
+		a.sendItemThresholdMenu(userID, q.Message.MessageID, chatID, parts[2])
+	case "trigbase":
+		// cycle last_post -> day_open -> minutes_ago
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		st, _ := a.db.GetChatSettings(ctx, chatID)
+		next := "day_open"
+		switch st.TriggerBaseline {
+		case "day_open":
+			next = "minutes_ago"
+		case "minutes_ago":
+			next = "last_post"
+		}
+		_ = a.db.UpdateChatSetting(ctx, chatID, "trigger_baseline", next)
+		a.sendTriggerMenu(userID, q.Message.MessageID, chatID)
+	case "trigbmin":
+		// trigbmin|chatID|deltaMinutes
+		if len(parts) < 3 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		delta, _ := strconv.Atoi(parts[2])
+		st, _ := a.db.GetChatSettings(ctx, chatID)
+		mins := st.TriggerBaselineMinutes + delta
+		if mins < 15 { mins = 15 }
+		_ = a.db.UpdateChatSetting(ctx, chatID, "trigger_baseline_minutes", mins)
+		a.sendTriggerMenu(userID, q.Message.MessageID, chatID)
+	case "trigcd", "trigms":
+		// trigcd|chatID|deltaMinutes (cooldown), trigms|chatID|deltaMinutes (max silence)
+		if len(parts) < 3 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		delta, _ := strconv.Atoi(parts[2])
+		st, _ := a.db.GetChatSettings(ctx, chatID)
+		key, cur := "trigger_cooldown_minutes", st.TriggerCooldownMinutes
+		if parts[0] == "trigms" {
+			key, cur = "trigger_max_silence_minutes", st.TriggerMaxSilenceMinutes
+		}
+		cur += delta
+		if cur < 0 { cur = 0 }
+		_ = a.db.UpdateChatSetting(ctx, chatID, key, cur)
+		a.sendTriggerMenu(userID, q.Message.MessageID, chatID)
 	case "tmpl":
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		a.sendTemplatesMenu(userID, q.Message.MessageID, chatID)
@@ -705,22 +920,17 @@ case "interval":
 		s.TemplateID = tid
 		s.Await = AwaitEditTemplateBody
 		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "متن جدید قالب را بفرستید.\n\nجایگزین‌ها: {CURRENCIES} {COINS} {GOLD} {DATETIME}"))
-	case "tmplmedia":
-		// tmplmedia|chatID|templateID
-		if len(parts) < 3 { return }
-		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
-		tid := parts[2]
-		s := a.ensureSession(userID)
-		s.SelectedChatID = chatID
-		s.TemplateID = tid
-		s.Await = AwaitSetTemplateMedia
-		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "لطفاً یک عکس یا ویدیو برای این قالب ارسال کنید (Caption لازم نیست)."))
+	case "tmplmedia", "tmplmd", "tmplmok":
+		a.handleTemplateMediaCallback(ctx, userID, q.Message.MessageID, parts)
 	case "tmplclear":
 		if len(parts) < 3 { return }
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		tid := parts[2]
 		_ = a.db.ClearTemplateMedia(ctx, tid)
-		a.sendTemplatesMenu(userID, q.Message.MessageID, chatID)
+		a.sendTemplateManageMenu(userID, q.Message.MessageID, chatID, tid, "")
+	case "tmplm", "tmplren", "tmpldup", "tmpldel", "tmpldelok",
+		"tmplhist", "tmplrv", "tmplrvp", "tmplrb":
+		a.handleTemplateManageCallback(ctx, userID, q.Message.MessageID, parts)
 	case "sendnow":
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "⏳ در حال ارسال بروزرسانی..."))
@@ -734,7 +944,10 @@ case "interval":
 		s := a.ensureSession(userID)
 		s.SelectedChatID = chatID
 		s.Await = AwaitImportSettings
-		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "تنظیمات را به صورت JSON در همین چت ارسال کنید (Paste)."))
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "فایل تنظیمات (JSON) را به صورت Document بفرستید یا متن آن را Paste کنید.\nقبل از اعمال، پیش‌نمایش تغییرات نمایش داده می‌شود."))
+	case "importok":
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		a.applyImport(ctx, userID, q.Message.MessageID, chatID)
 	case "status":
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		a.sendStatusMenu(userID, q.Message.MessageID, chatID)
@@ -760,10 +973,19 @@ case "interval":
 		if !isSuper { return }
 		if len(parts) < 2 { return }
 		rmID, _ := strconv.ParseInt(parts[1], 10, 64)
+		if rmID == userID { return }
 		_ = a.db.RemoveAdmin(ctx, rmID)
 		a.sendAdminsMenu(userID, q.Message.MessageID)
+	case "adminedit", "adminrole", "admchats", "admchat":
+		a.handleAdminRoleCallback(ctx, userID, q.Message.MessageID, parts)
 	case "globalsrc":
 		a.sendGlobalSourceMenu(userID, q.Message.MessageID)
+	case "defsrc":
+		// defsrc|key|value
+		if len(parts) < 3 { return }
+		if parts[1] != "default_source_provider" && parts[1] != "default_source_method" { return }
+		_ = a.db.SetGlobalSetting(ctx, parts[1], parts[2])
+		a.sendGlobalSourceMenu(userID, q.Message.MessageID)
 	case "setbonuser":
 		s := a.ensureSession(userID)
 		s.Await = AwaitSetBonUser
@@ -780,6 +1002,29 @@ case "interval":
 		a.sendBackupMenu(userID, q.Message.MessageID)
 	case "dbbackup":
 		a.sendDBBackup(userID)
+	case "probchats":
+		a.sendProblemChatsMenu(userID, q.Message.MessageID)
+	case "probclear":
+		if len(parts) < 2 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		_ = a.db.SetChatProblem(ctx, chatID, "")
+		a.sendProblemChatsMenu(userID, q.Message.MessageID)
+	case "chatforget":
+		if len(parts) < 2 { return }
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		ch, err := a.db.GetChat(ctx, chatID)
+		if err != nil || !ch.Orphaned() { return }
+		_ = a.db.DeleteChat(ctx, chatID)
+		a.sendProblemChatsMenu(userID, q.Message.MessageID)
+	case "approval", "apadm", "apdeny", "apexp", "apallow", "apallowclr":
+		a.handleApprovalCallback(ctx, userID, q.Message.MessageID, parts)
+	case "profiles", "prof", "profdel", "profsel", "profselt", "profselall", "profapply",
+		"cprof", "cprofset", "cproflink", "cprofrm", "cprofnew", "cprofsave":
+		a.handleProfileCallback(ctx, userID, q.Message.MessageID, parts)
+	case "clone", "clonesrc", "clonet", "cloneok":
+		a.handleCloneCallback(ctx, userID, q.Message.MessageID, parts)
+	case "auditcsv":
+		a.sendAuditCSV(userID)
 	case "dbrestore":
 		s := a.ensureSession(userID)
 		s.Await = AwaitRestoreDB
@@ -803,6 +1048,20 @@ case "interval":
 		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
 		_ = a.db.UpdateChatSetting(ctx, chatID, "trigger_items", []string{})
 		a.sendTriggerMenu(userID, q.Message.MessageID, chatID)
+	case "alerts", "aladd", "alitem", "alkind", "alrule", "alon", "alpin", "alcd", "alwin", "altmpl", "altmplrst", "aldel":
+		a.handleAlertCallback(ctx, userID, q.Message.MessageID, parts)
+	case "cmds":
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		a.sendCommandsMenu(userID, q.Message.MessageID, chatID)
+	case "opol":
+		chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+		a.sendOwnerPolicyMenu(userID, q.Message.MessageID, chatID)
+	case "opolon", "opoladj":
+		a.handleOwnerPolicyCallback(ctx, userID, q.Message.MessageID, parts)
+	case "cmdon", "cmdcd":
+		a.handleCommandsCallback(ctx, userID, q.Message.MessageID, parts)
+	case "digests", "dg", "dgon", "dgtime", "dgtmpl", "dgtmplrst", "dgprev":
+		a.handleDigestCallback(ctx, userID, q.Message.MessageID, parts)
 	case "noop":
 		// no-op (used for label buttons)
 		return
@@ -823,6 +1082,22 @@ func toggleInList(list []string, item string) []string {
 
 func (a *App) sendMainMenu(userID int64, msgID int) {
 	text := "⚙️ پنل مدیریت ربات نرخ ارز\n\nهمه چیز با دکمه‌ها (Inline) کنترل می‌شود.\n\nیکی را انتخاب کنید:"
+	if role, _ := a.db.GetRole(context.Background(), userID); role == db.RoleManager {
+		kb := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📣 چت‌های من", "chats|0"),
+				tgbotapi.NewInlineKeyboardButtonData("❓ راهنما", "help"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("👤 اشتراک و هشدار شخصی", "umenu"),
+			),
+		)
+		a.editOrSendMenu(userID, msgID, text, kb)
+		return
+	}
+	if pol, err := a.db.GetApprovalPolicy(context.Background()); err == nil {
+		text = "⚙️ پنل مدیریت ربات نرخ ارز\n\nهمه چیز با دکمه‌ها (Inline) کنترل می‌شود.\n🛂 تایید چت‌های جدید: " + approvalSummary(pol) + "\n\nیکی را انتخاب کنید:"
+	}
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📣 چت‌ها / کانال‌ها", "chats|0"),
@@ -833,15 +1108,22 @@ func (a *App) sendMainMenu(userID int64, msgID int) {
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("👥 مدیریت ادمین‌ها", "admins"),
+			tgbotapi.NewInlineKeyboardButtonData("🛂 سیاست تایید چت‌ها", "approval"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗂 پروفایل‌های تنظیمات", "profiles"),
 			tgbotapi.NewInlineKeyboardButtonData("❓ راهنما", "help"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👤 اشتراک و هشدار شخصی", "umenu"),
+		),
 	)
 	a.editOrSendMenu(userID, msgID, text, kb)
 }
 
 func (a *App) sendChatsMenu(userID int64, msgID int, page int) {
 	ctx := context.Background()
-	chats, err := a.db.ListChats(ctx)
+	chats, err := a.visibleChats(ctx, userID)
 	if err != nil {
 		return
 	}
@@ -855,7 +1137,9 @@ func (a *App) sendChatsMenu(userID int64, msgID int, page int) {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	for _, c := range chats[start:end] {
 		prefix := "⏳"
-		if c.Approved {
+		if c.Problem != "" {
+			prefix = "⚠️"
+		} else if c.Approved {
 			prefix = "✅"
 		}
 		icon := "👥"
@@ -878,11 +1162,16 @@ func (a *App) sendChatsMenu(userID int64, msgID int, page int) {
 	if len(navRow) > 0 {
 		rows = append(rows, navRow)
 	}
+	if n := len(problemChats(chats)); n > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚠️ چت‌های مشکل‌دار (%d)", n), "probchats"),
+		))
+	}
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "main"),
 	))
 
-	text := "📣 لیست چت‌ها/کانال‌هایی که ربات در آن‌ها عضو است:\n(⏳ یعنی هنوز تایید نشده)"
+	text := "📣 لیست چت‌ها/کانال‌هایی که ربات در آن‌ها عضو است:\n(⏳ یعنی هنوز تایید نشده، ⚠️ یعنی ربات نمی‌تواند ارسال کند)"
 	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
 	a.editOrSendMenu(userID, msgID, text, kb)
 }
@@ -932,6 +1221,9 @@ func (a *App) sendChatMenu(userID int64, msgID int, chatID int64) {
 		showSame,
 		st.TemplateID,
 	)
+	if ch.Problem != "" {
+		text += "\n\n⚠️ مشکل: " + problemLabel(ch.Problem)
+	}
 
 	rows := [][]tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardRow(
@@ -960,11 +1252,23 @@ func (a *App) sendChatMenu(userID int64, msgID int, chatID int64) {
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("▬/▲ نمایش حالت بدون تغییر", fmt.Sprintf("same|%d", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("🚨 هشدارها", fmt.Sprintf("alerts|%d", chatID)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔢 Digits", fmt.Sprintf("digits|%d", chatID)),
 			tgbotapi.NewInlineKeyboardButtonData("🧾 قالب‌ها + Preview", fmt.Sprintf("tmpl|%d", chatID)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📊 خلاصه‌ها", fmt.Sprintf("digests|%d", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("💬 دستورات گروه", fmt.Sprintf("cmds|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏷 مدیریت توسط ادمین‌های چت", fmt.Sprintf("opol|%d", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("🗂 پروفایل", fmt.Sprintf("cprof|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 کپی تنظیمات از چت دیگر…", fmt.Sprintf("clone|%d", chatID)),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🚀 ارسال الآن", fmt.Sprintf("sendnow|%d", chatID)),
 			tgbotapi.NewInlineKeyboardButtonData("📤 Export", fmt.Sprintf("export|%d", chatID)),
@@ -1139,12 +1443,19 @@ func (a *App) sendPriceMenu(userID int64, msgID int, chatID int64) {
 func (a *App) sendPostModeMenu(userID int64, msgID int, chatID int64) {
 	ctx := context.Background()
 	st, _ := a.db.GetChatSettings(ctx, chatID)
-	text := fmt.Sprintf("✉️ نوع ارسال\n\nحالت فعلی: %s\n\nNew: پیام جدید هر بار\nEdit: ادیت پیام قبلی (کم‌اسپم)", st.PostMode)
+	text := fmt.Sprintf("✉️ نوع ارسال\n\nحالت فعلی: %s\n\nNew: پیام جدید هر بار\nEdit: ادیت پیام قبلی (کم‌اسپم)\nRepost: حذف پیام قبلی و ارسال دوباره (نیاز به دسترسی حذف پیام)\nPin: یک پیام پین‌شده که هر بار ادیت می‌شود، و با فعال شدن تریگر یک پیام جدید جدا (نیاز به دسترسی پین)\n\nدر حالت Edit/Pin اگر متن تغییری نکرده باشد ادیت انجام نمی‌شود.\nنادیده گرفتن تغییر فقط ساعت/تاریخ: %v", st.PostMode, st.EditIgnoreTime)
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("New message", fmt.Sprintf("postset|%d|new", chatID)),
 			tgbotapi.NewInlineKeyboardButtonData("Edit latest", fmt.Sprintf("postset|%d|edit", chatID)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Delete & repost", fmt.Sprintf("postset|%d|repost", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("📌 Pinned board", fmt.Sprintf("postset|%d|pin", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🕒 نادیده گرفتن تغییر ساعت", fmt.Sprintf("editskip|%d", chatID)),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", chatID)),
 		),
@@ -1244,8 +1555,32 @@ func (a *App) sendTriggerMenu(userID int64, msgID int, chatID int64) {
 			mark = "🎯"
 		}
 		label := fmt.Sprintf("%s %s %s", mark, it.Emoji, truncate(it.NameFa, 18))
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		row := tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("trigtog|%d|%s", chatID, id)),
+		)
+		if trigSet[id] {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("📏 "+formatThreshold(st.ThresholdFor(id)), fmt.Sprintf("tith|%d|%s", chatID, id)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Cooldown -5m", fmt.Sprintf("trigcd|%d|-5", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("Cooldown +5m", fmt.Sprintf("trigcd|%d|5", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Max silence -30m", fmt.Sprintf("trigms|%d|-30", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("Max silence +30m", fmt.Sprintf("trigms|%d|30", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📐 مبنا: "+baselineLabel(st), fmt.Sprintf("trigbase|%d", chatID)),
+		),
+	)
+	if st.TriggerBaseline == "minutes_ago" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("-15m", fmt.Sprintf("trigbmin|%d|-15", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("+15m", fmt.Sprintf("trigbmin|%d|15", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("+60m", fmt.Sprintf("trigbmin|%d|60", chatID)),
 		))
 	}
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
@@ -1254,7 +1589,92 @@ func (a *App) sendTriggerMenu(userID int64, msgID int, chatID int64) {
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", chatID)),
 	))
-	text := fmt.Sprintf("🎯 Trigger\n\nاگر Triggerها تنظیم شوند، فقط وقتی تغییر کنند پست می‌شود.\nTrigger فعلی: %d مورد", len(st.TriggerItems))
+	text := fmt.Sprintf("🎯 Trigger\n\nاگر Triggerها تنظیم شوند، فقط وقتی تغییر کنند پست می‌شود.\nTrigger فعلی: %d مورد\n\n📏 آستانه هر آیتم جداگانه قابل تنظیم است (پیش‌فرض: %s).\nCooldown (حداقل فاصله بین پست‌ها): %s\nMax silence (پست حتی بدون تغییر بعد از): %s\n\n📐 مبنای مقایسه (برای Trigger و فلش‌ها): %s\nبا مبنای «ابتدای روز» یا «دقیقه قبل»، تغییر تا برگشت قیمت باقی می‌ماند؛ برای جلوگیری از پست در هر دقیقه Cooldown حداقل %d دقیقه اعمال می‌شود.",
+		len(st.TriggerItems), formatThreshold(db.TriggerThreshold{Type: st.TriggerThresholdType, Value: st.TriggerThresholdValue}),
+		minutesOrOff(scheduler.TriggerCooldown(st)), minutesOrOff(st.TriggerMaxSilenceMinutes), baselineLabel(st), scheduler.MinHistoryCooldownMinutes)
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func formatThreshold(th db.TriggerThreshold) string {
+	if th.Type == "pct" {
+		return strconv.FormatFloat(th.Value, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatFloat(th.Value, 'f', -1, 64)
+}
+
+func baselineLabel(st db.ChatSettings) string {
+	switch st.TriggerBaseline {
+	case "day_open":
+		return "از ابتدای روز"
+	case "minutes_ago":
+		return fmt.Sprintf("%d دقیقه قبل", st.TriggerBaselineMinutes)
+	default:
+		return "از آخرین پست"
+	}
+}
+
+func minutesOrOff(m int) string {
+	if m <= 0 {
+		return "خاموش"
+	}
+	return fmt.Sprintf("%d دقیقه", m)
+}
+
+// sendItemThresholdMenu edits the trigger threshold of a single item.
+func (a *App) sendItemThresholdMenu(userID int64, msgID int, chatID int64, itemID string) {
+	ctx := context.Background()
+	st, _ := a.db.GetChatSettings(ctx, chatID)
+	it, ok := items.ByID(itemID)
+	if !ok {
+		return
+	}
+	th := st.ThresholdFor(itemID)
+	_, custom := st.ItemThresholds[itemID]
+	src := "پیش‌فرض چت"
+	if custom {
+		src = "اختصاصی"
+	}
+	unit := "تومان"
+	if it.BonbastUnit == items.UnitUSD {
+		unit = "دلار"
+	}
+	if th.Type == "pct" {
+		unit = "%"
+	}
+	text := fmt.Sprintf("📏 Threshold برای %s %s\n\nنوع: %s\nمقدار: %s %s\nمنبع: %s", it.Emoji, it.NameFa, th.Type, strconv.FormatFloat(th.Value, 'f', -1, 64), unit, src)
+
+	var steps []string
+	switch {
+	case th.Type == "pct":
+		steps = []string{"-0.1", "0.1", "1"}
+	case it.BonbastUnit == items.UnitUSD:
+		steps = []string{"-1", "1", "10"}
+	default:
+		steps = []string{"-100", "100", "1000"}
+	}
+	adj := []tgbotapi.InlineKeyboardButton{}
+	for _, d := range steps {
+		label := d
+		if !strings.HasPrefix(d, "-") {
+			label = "+" + d
+		}
+		adj = append(adj, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("tithadj|%d|%s|%s", chatID, itemID, d)))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("تغییر نوع (abs/pct)", fmt.Sprintf("tithtype|%d|%s", chatID, itemID)),
+		),
+		adj,
+	}
+	if custom {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ برگشت به پیش‌فرض چت", fmt.Sprintf("tithreset|%d|%s", chatID, itemID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("trig|%d", chatID)),
+	))
 	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
 	a.editOrSendMenu(userID, msgID, text, kb)
 }
@@ -1267,7 +1687,7 @@ func (a *App) sendThresholdMenu(userID int64, msgID int, chatID int64) {
 	if st.TriggerThresholdType == "pct" {
 		unit = "%"
 	}
-	text := fmt.Sprintf("📏 Threshold\n\nنوع: %s\nمقدار: %.2f %s\n\n(برای Triggerها استفاده می‌شود)", st.TriggerThresholdType, st.TriggerThresholdValue, unit)
+	text := fmt.Sprintf("📏 Threshold (پیش‌فرض چت)\n\nنوع: %s\nمقدار: %.2f %s\n\n(برای Triggerهایی که آستانه اختصاصی ندارند استفاده می‌شود)", st.TriggerThresholdType, st.TriggerThresholdValue, unit)
 
 	rows := [][]tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardRow(
@@ -1311,19 +1731,14 @@ func (a *App) sendTemplatesMenu(userID int64, msgID int, chatID int64) {
 			mark = "✅"
 		}
 		label := mark + " " + truncate(t.Name, 22)
+		if t.IsBuiltin {
+			label += " 🔒"
+		}
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("tmplset|%d|%s", chatID, t.TemplateID)),
-			tgbotapi.NewInlineKeyboardButtonData("👁 Preview", fmt.Sprintf("tmplprev|%d|%s", chatID, t.TemplateID)),
+			tgbotapi.NewInlineKeyboardButtonData("👁", fmt.Sprintf("tmplprev|%d|%s", chatID, t.TemplateID)),
+			tgbotapi.NewInlineKeyboardButtonData("⚙️", fmt.Sprintf("tmplm|%d|%s", chatID, t.TemplateID)),
 		))
-		// extra row for media/edit for custom templates
-		editBtns := []tgbotapi.InlineKeyboardButton{
-			tgbotapi.NewInlineKeyboardButtonData("✏️ Edit", fmt.Sprintf("tmpledit|%d|%s", chatID, t.TemplateID)),
-			tgbotapi.NewInlineKeyboardButtonData("🖼/🎥 Media", fmt.Sprintf("tmplmedia|%d|%s", chatID, t.TemplateID)),
-		}
-		if t.MediaType != "" {
-			editBtns = append(editBtns, tgbotapi.NewInlineKeyboardButtonData("🧹 حذف مدیا", fmt.Sprintf("tmplclear|%d|%s", chatID, t.TemplateID)))
-		}
-		rows = append(rows, editBtns)
 	}
 
 	rows = append(rows,
@@ -1335,12 +1750,22 @@ func (a *App) sendTemplatesMenu(userID int64, msgID int, chatID int64) {
 		),
 	)
 
-	text := "🧾 قالب‌ها\n\nبا دکمه Preview می‌توانید قبل از ارسال به کانال/گروه ببینید."
+	text := "🧾 قالب‌ها\n\n👁 پیش‌نمایش بدون ارسال به کانال/گروه\n⚙️ مدیریت قالب (ویرایش، تغییر نام، مدیا، کپی، حذف)\n🔒 قالب پیش‌فرض؛ برای تغییر از آن کپی بگیرید."
 	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
 	a.editOrSendMenu(userID, msgID, text, kb)
 }
 
 func (a *App) previewTemplate(userID int64, chatID int64, templateID string) {
+	tmpl, err := a.db.GetTemplate(context.Background(), templateID)
+	if err != nil {
+		return
+	}
+	a.sendTemplatePreview(userID, chatID, tmpl)
+}
+
+// sendTemplatePreview renders tmpl with chatID's settings and current prices
+// and sends it to userID only.
+func (a *App) sendTemplatePreview(userID int64, chatID int64, tmpl db.Template) {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
@@ -1348,10 +1773,6 @@ func (a *App) previewTemplate(userID int64, chatID int64, templateID string) {
 	if err != nil {
 		return
 	}
-	tmpl, err := a.db.GetTemplate(ctx, templateID)
-	if err != nil {
-		return
-	}
 
 	enabledIDs, _ := a.db.EnabledItemIDs(ctx, chatID)
 	snap, err := a.sources.Get(ctx, sources.Provider(settings.SourceProvider), sources.Method(settings.SourceMethod))
@@ -1359,7 +1780,7 @@ func (a *App) previewTemplate(userID int64, chatID int64, templateID string) {
 		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ دریافت دیتا ناموفق: "+err.Error()))
 		return
 	}
-	lastVals, _ := a.db.GetLastValues(ctx, chatID, enabledIDs)
+	lastVals, _ := scheduler.Baseline(ctx, a.db, settings, enabledIDs)
 
 	out := render.BuildMessage(ctx, settings, tmpl, enabledIDs, snap, lastVals)
 
@@ -1367,19 +1788,9 @@ func (a *App) previewTemplate(userID int64, chatID int64, templateID string) {
 	header := "👁 Preview قالب: " + tmpl.Name + "\n(این فقط پیش‌نمایش است و در کانال/گروه پست نمی‌شود.)"
 	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, header))
 
-	if out.MediaType != "" && out.MediaFileID != "" {
-		if out.MediaType == "video" {
-			msg := tgbotapi.NewVideo(userID, tgbotapi.FileID(out.MediaFileID))
-			msg.Caption = out.Text
-			_, _ = a.bot.Send(msg)
-			return
-		}
-		msg := tgbotapi.NewPhoto(userID, tgbotapi.FileID(out.MediaFileID))
-		msg.Caption = out.Text
-		_, _ = a.bot.Send(msg)
-		return
+	if _, err := a.bot.Request(scheduler.BoardMessage(userID, out)); err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ارسال پیش‌نمایش ناموفق: "+err.Error()))
 	}
-	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, out.Text))
 }
 
 func (a *App) exportSettings(userID int64, chatID int64) {
@@ -1419,8 +1830,15 @@ func (a *App) sendStatusMenu(userID int64, msgID int, chatID int64) {
 	if st.LastError.Valid {
 		errTxt = st.LastError.String
 	}
-	text := fmt.Sprintf("🧰 Status / Health\n\nچت: %s\nChat ID: %d\nApproved: %v\nEnabled: %v\n\nLast fetch: %s\nLast post: %s\nCurrent source: %s (%s)\nErrors: %s",
-		ch.Title, ch.ChatID, ch.Approved, ch.Enabled, lastFetch, lastPost, st.SourceProvider, st.SourceMethod, errTxt)
+	dropTxt := "0"
+	if ds := a.out.Drops(chatID); ds.Count > 0 {
+		dropTxt = fmt.Sprintf("%d (آخرین: %s — %s)", ds.Count, ds.LastAt.In(utils.TehranLoc()).Format(time.RFC3339), ds.LastError)
+	}
+	text := fmt.Sprintf("🧰 Status / Health\n\nچت: %s\nChat ID: %d\nApproved: %v\nEnabled: %v\n\nLast fetch: %s\nLast post: %s\nCurrent source: %s (%s)\nErrors: %s\nDropped sends: %s",
+		ch.Title, ch.ChatID, ch.Approved, ch.Enabled, lastFetch, lastPost, st.SourceProvider, st.SourceMethod, errTxt, dropTxt)
+	if entries, _ := a.db.ListAudit(ctx, chatID, 10); len(entries) > 0 {
+		text += "\n\n📝 آخرین تغییرات:" + auditLines(entries)
+	}
 
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -1438,11 +1856,7 @@ func (a *App) sendAdminsMenu(userID int64, msgID int) {
 	var b strings.Builder
 	b.WriteString("👥 مدیریت ادمین‌ها\n\n")
 	for _, ad := range admins {
-		tag := ""
-		if ad.IsSuper {
-			tag = " (super)"
-		}
-		b.WriteString(fmt.Sprintf("• %d%s\n", ad.UserID, tag))
+		b.WriteString(fmt.Sprintf("• %d — %s\n", ad.UserID, roleLabels[ad.Role]))
 	}
 	text := b.String()
 
@@ -1456,7 +1870,7 @@ func (a *App) sendAdminsMenu(userID int64, msgID int) {
 			continue
 		}
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("❌ حذف %d", ad.UserID), fmt.Sprintf("adminrm|%d", ad.UserID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚙️ %d (%s)", ad.UserID, roleLabels[ad.Role]), fmt.Sprintf("adminedit|%d", ad.UserID)),
 		))
 	}
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
@@ -1471,9 +1885,10 @@ func (a *App) sendGlobalSourceMenu(userID int64, msgID int) {
 	bonUser, _, _ := a.db.GetGlobalSetting(ctx, "bonbast_api_username")
 	bonHash, _, _ := a.db.GetGlobalSetting(ctx, "bonbast_api_hash")
 	navKey, _, _ := a.db.GetGlobalSetting(ctx, "navasan_api_key")
+	defProvider, defMethod := a.defaultSource(ctx)
 
-	text := fmt.Sprintf("🧩 تنظیمات منبع داده (Global)\n\nBonbast API username: %s\nBonbast API hash: %s\nNavasan API key: %s\n\nاگر کلید ندارید، می‌توانید از روش Scrape استفاده کنید.\n\nPros/Cons:\n• API: پایدارتر + کمتر احتمال بلاک، اما نیاز به کلید/هزینه.\n• Scrape: بدون کلید، اما ممکن است تغییر کند یا محدود شود.",
-		blankOrValue(bonUser), maskSecret(bonHash), maskSecret(navKey))
+	text := fmt.Sprintf("🧩 تنظیمات منبع داده (Global)\n\nBonbast API username: %s\nBonbast API hash: %s\nNavasan API key: %s\nمنبع پیش‌فرض (Inline/تبدیل): %s (%s)\n\nاگر کلید ندارید، می‌توانید از روش Scrape استفاده کنید.\n\nPros/Cons:\n• API: پایدارتر + کمتر احتمال بلاک، اما نیاز به کلید/هزینه.\n• Scrape: بدون کلید، اما ممکن است تغییر کند یا محدود شود.",
+		blankOrValue(bonUser), maskSecret(bonHash), maskSecret(navKey), defProvider, defMethod)
 
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -1483,6 +1898,14 @@ func (a *App) sendGlobalSourceMenu(userID int64, msgID int) {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Set Navasan key", "setnavkey"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("پیش‌فرض: Bonbast", "defsrc|default_source_provider|bonbast"),
+			tgbotapi.NewInlineKeyboardButtonData("پیش‌فرض: Navasan", "defsrc|default_source_provider|navasan"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("روش: API", "defsrc|default_source_method|api"),
+			tgbotapi.NewInlineKeyboardButtonData("روش: Scrape", "defsrc|default_source_method|scrape"),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "main"),
 		),
@@ -1515,6 +1938,9 @@ func (a *App) sendBackupMenu(userID int64, msgID int) {
 			tgbotapi.NewInlineKeyboardButtonData("📦 Backup DB", "dbbackup"),
 			tgbotapi.NewInlineKeyboardButtonData("♻️ Restore DB", "dbrestore"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📝 Audit log (CSV)", "auditcsv"),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "main"),
 		),
@@ -1608,14 +2034,18 @@ func (a *App) restoreDBFromTelegram(ctx context.Context, userID int64, doc tgbot
 		newDB, _ = db.Open(a.dbPath)
 		a.db = newDB
 		a.sources = sources.NewManager(newDB)
-		a.sched = scheduler.New(a.db, a.sources, a.bot, a)
+		a.leader.SetDB(newDB)
+		a.sched = scheduler.New(a.db, a.sources, a.out, a)
+		a.sched.SetLeaderCheck(a.leader.IsLeader)
 		a.sched.Start()
 		return err
 	}
 
 	a.db = newDB
+	a.leader.SetDB(newDB)
 	a.sources = sources.NewManager(newDB)
-	a.sched = scheduler.New(a.db, a.sources, a.bot, a)
+	a.sched = scheduler.New(a.db, a.sources, a.out, a)
+	a.sched.SetLeaderCheck(a.leader.IsLeader)
 	a.sched.Start()
 	return nil
 }
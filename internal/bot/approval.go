@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+var approvalExpiryPresets = []int{0, 24, 72, 168}
+
+// approvalSummary is the one-line policy shown in the main menu.
+func approvalSummary(p db.ApprovalPolicy) string {
+	parts := []string{}
+	if p.AutoApproveAdmins {
+		parts = append(parts, "خودکار برای ادمین‌ها")
+	}
+	if len(p.Allowlist) > 0 {
+		parts = append(parts, fmt.Sprintf("لیست مجاز (%d)", len(p.Allowlist)))
+	}
+	if p.DenyStrangers {
+		parts = append(parts, "رد غریبه‌ها")
+	}
+	if p.ExpiryHours > 0 {
+		parts = append(parts, fmt.Sprintf("انقضا %dh", p.ExpiryHours))
+	}
+	if len(parts) == 0 {
+		return "دستی"
+	}
+	return strings.Join(parts, " + ")
+}
+
+// applyApprovalPolicy approves or denies a chat the bot was just added to
+// according to the approval policy. It returns false if the chat should go
+// through the manual approve/deny flow.
+func (a *App) applyApprovalPolicy(m tgbotapi.ChatMemberUpdated) bool {
+	ctx := context.Background()
+	pol, err := a.db.GetApprovalPolicy(ctx)
+	if err != nil {
+		return false
+	}
+	chat := m.Chat
+	role, _ := a.db.GetRole(ctx, m.From.ID)
+	byAdmin := role == db.RoleSuper || role == db.RoleAdmin
+
+	var reason string
+	approve := false
+	switch {
+	case pol.Allowed(chat.ID, chat.UserName):
+		approve, reason = true, "لیست مجاز"
+	case pol.AutoApproveAdmins && byAdmin:
+		approve, reason = true, "اضافه شده توسط ادمین ربات"
+	case pol.DenyStrangers && role == "":
+		reason = "اضافه شده توسط کاربر ناشناس"
+	default:
+		return false
+	}
+
+	title := chat.Title
+	if title == "" {
+		title = chat.UserName
+	}
+	from := fmt.Sprintf("%s (%d)", displayName(m.From), m.From.ID)
+	if approve {
+		_ = a.db.SetChatApproved(ctx, chat.ID, true)
+		_ = a.db.SetChatEnabled(ctx, chat.ID, true)
+		_, _ = a.bot.Send(tgbotapi.NewMessage(chat.ID, "✅ ربات اضافه و به صورت خودکار تایید شد. بروزرسانی‌ها طبق تنظیمات شروع می‌شود."))
+		kb := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("⚙️ تنظیمات", fmt.Sprintf("chat|%d", chat.ID)),
+				tgbotapi.NewInlineKeyboardButtonData("❌ رد", fmt.Sprintf("deny|%d", chat.ID)),
+			),
+		)
+		a.NotifyAdmins(ctx, fmt.Sprintf("✅ تایید خودکار (%s):\n\nعنوان: %s\nChat ID: %d\nاضافه‌کننده: %s", reason, title, chat.ID, from), &kb)
+		return true
+	}
+
+	_ = a.db.SetChatApproved(ctx, chat.ID, false)
+	_ = a.db.SetChatEnabled(ctx, chat.ID, false)
+	_, _ = a.bot.Send(tgbotapi.NewMessage(chat.ID, "❌ این ربات فقط توسط ادمین‌هایش قابل افزودن است و از این چت خارج می‌شود."))
+	_, _ = a.bot.Request(tgbotapi.LeaveChatConfig{ChatID: chat.ID})
+	a.NotifyAdmins(ctx, fmt.Sprintf("🚫 رد خودکار و خروج (%s):\n\nعنوان: %s\nChat ID: %d\nاضافه‌کننده: %s", reason, title, chat.ID, from), nil)
+	return true
+}
+
+func (a *App) sendApprovalMenu(userID int64, msgID int) {
+	pol, err := a.db.GetApprovalPolicy(context.Background())
+	if err != nil {
+		return
+	}
+	onOff := func(b bool) string {
+		if b {
+			return "✅"
+		}
+		return "⬜️"
+	}
+	allow := "—"
+	if len(pol.Allowlist) > 0 {
+		allow = strings.Join(pol.Allowlist, ", ")
+	}
+	expiry := "خاموش"
+	if pol.ExpiryHours > 0 {
+		expiry = fmt.Sprintf("%d ساعت", pol.ExpiryHours)
+	}
+	text := fmt.Sprintf("🛂 سیاست تایید چت‌های جدید\n\nخلاصه: %s\n\n%s تایید خودکار وقتی ادمین ربات (اصلی/کل) آن را اضافه کند\n%s رد و خروج وقتی کسی غیر از ادمین‌های ربات اضافه کند\nلیست مجاز (تایید خودکار): %s\nانقضای درخواست‌های در انتظار: %s\n\nترتیب بررسی: لیست مجاز ← ادمین ربات ← رد غریبه‌ها ← تایید دستی. درخواستی که تا پایان مهلت تایید نشود رد می‌شود و ربات از چت خارج می‌شود.",
+		approvalSummary(pol), onOff(pol.AutoApproveAdmins), onOff(pol.DenyStrangers), allow, expiry)
+
+	expRow := []tgbotapi.InlineKeyboardButton{}
+	for _, h := range approvalExpiryPresets {
+		label := fmt.Sprintf("%dh", h)
+		if h == 0 {
+			label = "بدون انقضا"
+		}
+		if h == pol.ExpiryHours {
+			label = "• " + label
+		}
+		expRow = append(expRow, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("apexp|%d", h)))
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(onOff(pol.AutoApproveAdmins)+" تایید خودکار ادمین‌ها", "apadm"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(onOff(pol.DenyStrangers)+" رد غریبه‌ها", "apdeny"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📝 ویرایش لیست مجاز", "apallow"),
+			tgbotapi.NewInlineKeyboardButtonData("🧹 پاک کردن لیست", "apallowclr"),
+		),
+		expRow,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "main"),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) handleApprovalCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	pol, err := a.db.GetApprovalPolicy(ctx)
+	if err != nil {
+		return
+	}
+	switch parts[0] {
+	case "apadm":
+		_ = a.db.SetApprovalFlag(ctx, db.ApprovalAutoAdminKey, !pol.AutoApproveAdmins)
+	case "apdeny":
+		_ = a.db.SetApprovalFlag(ctx, db.ApprovalDenyStrangersKey, !pol.DenyStrangers)
+	case "apexp":
+		if len(parts) < 2 { return }
+		h, err := strconv.Atoi(parts[1])
+		if err != nil || h < 0 { return }
+		_ = a.db.SetGlobalSetting(ctx, db.ApprovalExpiryHoursKey, strconv.Itoa(h))
+	case "apallow":
+		a.ensureSession(userID).Await = AwaitApprovalAllowlist
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "Chat ID ها و/یا یوزرنیم‌های مجاز را بفرستید (جدا با فاصله، کاما یا خط جدید).\nمثال:\n-1001234567890 @mychannel\n\nاین لیست جایگزین لیست فعلی می‌شود."))
+		return
+	case "apallowclr":
+		_ = a.db.SetGlobalSetting(ctx, db.ApprovalAllowlistKey, "")
+	}
+	a.sendApprovalMenu(userID, msgID)
+}
+
+func (a *App) onApprovalAllowlistMessage(ctx context.Context, msg tgbotapi.Message) {
+	userID := msg.From.ID
+	entries, invalid := db.ParseAllowlist(msg.Text)
+	if len(invalid) > 0 {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ این موارد معتبر نیستند: "+strings.Join(invalid, " ")+"\nدوباره بفرستید."))
+		return
+	}
+	a.clearAwait(userID)
+	_ = a.db.SetGlobalSetting(ctx, db.ApprovalAllowlistKey, strings.Join(entries, ","))
+	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, fmt.Sprintf("✅ لیست مجاز ذخیره شد (%d مورد).", len(entries))))
+	a.sendApprovalMenu(userID, 0)
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// auditLines formats audit entries for the status panel, one per line.
+// Long values (template bodies) are shortened; the CSV export has them in full.
+func auditLines(entries []db.AuditEntry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(fmt.Sprintf("\n• %s — %d: %s %s → %s",
+			e.At.In(utils.TehranLoc()).Format("01-02 15:04"), e.AdminID, e.Key,
+			auditShort(e.OldValue), auditShort(e.NewValue)))
+	}
+	return b.String()
+}
+
+func auditShort(v string) string {
+	if v == "" {
+		return "∅"
+	}
+	return truncate(strings.ReplaceAll(v, "\n", " "), 24)
+}
+
+func (a *App) sendAuditCSV(userID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var buf bytes.Buffer
+	if err := a.db.WriteAuditCSV(ctx, &buf); err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ خروجی Audit ناموفق: "+err.Error()))
+		return
+	}
+	name := fmt.Sprintf("audit_%s.csv", time.Now().In(utils.TehranLoc()).Format("20060102_1504"))
+	doc := tgbotapi.NewDocument(userID, tgbotapi.FileBytes{Name: name, Bytes: buf.Bytes()})
+	doc.Caption = "📝 Audit log"
+	_, _ = a.bot.Send(doc)
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+// maxImportSize caps an uploaded settings file.
+const maxImportSize = 1 << 20
+
+var importSectionLabels = map[string]string{
+	"settings": "تنظیمات",
+	"template": "قالب",
+	"items":    "اقلام",
+	"alerts":   "هشدارها",
+	"digests":  "خلاصه‌ها",
+}
+
+// importPayload returns the settings JSON of msg: pasted text or an uploaded document.
+func (a *App) importPayload(msg tgbotapi.Message) ([]byte, error) {
+	if msg.Document == nil {
+		return []byte(msg.Text), nil
+	}
+	if msg.Document.FileSize > maxImportSize {
+		return nil, fmt.Errorf("file is larger than %d KB", maxImportSize>>10)
+	}
+	f, err := a.bot.GetFile(tgbotapi.FileConfig{FileID: msg.Document.FileID})
+	if err != nil {
+		return nil, err
+	}
+	rc, err := httpGetSimple(f.Link(a.cfg.BotToken))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxImportSize))
+}
+
+// onImportSettingsMessage validates a settings file and shows a dry-run
+// report; nothing is applied until the admin confirms with importok.
+func (a *App) onImportSettingsMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	chatID := sess.SelectedChatID
+	if chatID == 0 {
+		a.clearAwait(userID)
+		return
+	}
+	data, err := a.importPayload(msg)
+	if err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ دریافت فایل ناموفق: "+err.Error()))
+		return
+	}
+	b, err := db.ParseBundle(data)
+	if err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ فایل تنظیمات نامعتبر است:\n"+truncate(err.Error(), 3500)+"\n\nفایل درست را دوباره بفرستید."))
+		return
+	}
+	rep, err := a.db.DryRunImport(ctx, chatID, b)
+	if err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ خطا در Import: "+err.Error()))
+		return
+	}
+	a.clearAwait(userID)
+	sess.ImportData = data
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if len(rep.Changes) > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ اعمال (%d تغییر)", len(rep.Changes)), fmt.Sprintf("importok|%d", chatID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ انصراف", fmt.Sprintf("chat|%d", chatID)),
+	))
+	m := tgbotapi.NewMessage(userID, importReportText(rep))
+	m.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	_, _ = a.bot.Send(m)
+}
+
+func importReportText(rep db.ImportReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧪 پیش‌نمایش Import (نسخه %d فایل)\nهنوز چیزی تغییر نکرده است.\n\n", rep.Version)
+	if rep.Version < db.BundleVersion {
+		b.WriteString("ℹ️ فایل نسخه قدیمی است و فقط تنظیمات و اقلام را دارد.\n\n")
+	}
+	if rep.SkippedTemplate != "" {
+		fmt.Fprintf(&b, "⚠️ قالب %q در این ربات وجود ندارد؛ قالب فعلی چت حفظ می‌شود.\n\n", rep.SkippedTemplate)
+	}
+	if len(rep.Changes) == 0 {
+		b.WriteString("تنظیمات فایل با تنظیمات فعلی یکسان است.")
+		return b.String()
+	}
+	b.WriteString("تغییرات (فعلی → جدید):\n")
+	for _, c := range rep.Changes {
+		line := fmt.Sprintf("• [%s] %s: %s → %s\n", importSectionLabels[c.Section], c.Key, truncate(orDash(c.From), 60), truncate(orDash(c.To), 60))
+		if b.Len()+len(line) > 3300 {
+			b.WriteString("…\n")
+			break
+		}
+		b.WriteString(line)
+	}
+	if rep.NewTemplate {
+		b.WriteString("\n🧾 قالب این فایل در این ربات وجود ندارد و به عنوان قالب جدید ساخته می‌شود.")
+	}
+	if rep.MediaFileID {
+		b.WriteString("\n⚠️ مدیای قالب با file_id ذخیره شده و فقط اگر با همین ربات آپلود شده باشد کار می‌کند.")
+	}
+	return b.String()
+}
+
+// applyImport applies the settings file saved by onImportSettingsMessage.
+func (a *App) applyImport(ctx context.Context, userID int64, msgID int, chatID int64) {
+	s := a.ensureSession(userID)
+	data := s.ImportData
+	s.ImportData = nil
+	if data == nil { return }
+	err := a.db.ImportChatSettings(ctx, chatID, data)
+	if err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ خطا در Import: "+err.Error()))
+	} else {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ تنظیمات وارد شد."))
+	}
+	a.sendChatMenu(userID, msgID, chatID)
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+var cloneSectionLabels = map[string]string{
+	"items":    "💱 اقلام و ترتیب",
+	"schedule": "🕒 زمان‌بندی (بازه + downtime)",
+	"template": "🧾 قالب و نمایش",
+	"triggers": "🎯 تریگرها و Threshold",
+}
+
+// handleCloneCallback serves the "copy settings from…" flow of a chat:
+// clone|dst, clonesrc|dst|src, clonet|dst|src|section, cloneok|dst|src.
+func (a *App) handleCloneCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 2 { return }
+	dstID, _ := strconv.ParseInt(parts[1], 10, 64)
+	if parts[0] == "clone" {
+		s := a.ensureSession(userID)
+		s.CloneSel = map[string]bool{}
+		for _, sec := range db.CloneSections {
+			s.CloneSel[sec] = true
+		}
+		a.sendCloneSourceMenu(ctx, userID, msgID, dstID)
+		return
+	}
+	if len(parts) < 3 { return }
+	srcID, _ := strconv.ParseInt(parts[2], 10, 64)
+	// The source chat must be visible to this admin too (chat managers).
+	if !a.canSeeChat(ctx, userID, srcID) { return }
+
+	s := a.ensureSession(userID)
+	if s.CloneSel == nil {
+		s.CloneSel = map[string]bool{}
+	}
+	switch parts[0] {
+	case "clonesrc":
+		a.sendClonePreview(ctx, userID, msgID, dstID, srcID)
+	case "clonet":
+		if len(parts) < 4 { return }
+		s.CloneSel[parts[3]] = !s.CloneSel[parts[3]]
+		a.sendClonePreview(ctx, userID, msgID, dstID, srcID)
+	case "cloneok":
+		sections := selectedCloneSections(s.CloneSel)
+		if len(sections) == 0 { return }
+		if err := a.db.CloneChatSettings(ctx, srcID, dstID, sections); err != nil {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ کپی تنظیمات ناموفق: "+err.Error()))
+			return
+		}
+		s.CloneSel = nil
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ تنظیمات کپی شد."))
+		a.sendChatMenu(userID, msgID, dstID)
+	}
+}
+
+func (a *App) canSeeChat(ctx context.Context, userID, chatID int64) bool {
+	chats, err := a.visibleChats(ctx, userID)
+	if err != nil {
+		return false
+	}
+	for _, c := range chats {
+		if c.ChatID == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+func selectedCloneSections(sel map[string]bool) []string {
+	out := []string{}
+	for _, sec := range db.CloneSections {
+		if sel[sec] {
+			out = append(out, sec)
+		}
+	}
+	return out
+}
+
+func (a *App) sendCloneSourceMenu(ctx context.Context, userID int64, msgID int, dstID int64) {
+	chats, _ := a.visibleChats(ctx, userID)
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, c := range chats {
+		if c.ChatID == dstID {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(truncate(c.Title, 30), fmt.Sprintf("clonesrc|%d|%d", dstID, c.ChatID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", dstID)),
+	))
+	text := "📋 کپی تنظیمات از چت دیگر\n\nچت مبدا را انتخاب کنید. قبل از اعمال، تغییرات نمایش داده می‌شود."
+	if len(rows) == 1 {
+		text += "\n\n(چت دیگری وجود ندارد.)"
+	}
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) sendClonePreview(ctx context.Context, userID int64, msgID int, dstID, srcID int64) {
+	sel := a.ensureSession(userID).CloneSel
+	sections := selectedCloneSections(sel)
+	src, _ := a.db.GetChat(ctx, srcID)
+	dst, _ := a.db.GetChat(ctx, dstID)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 کپی تنظیمات\nاز: %s\nبه: %s\n\n", src.Title, dst.Title)
+	diffs, err := a.db.DiffChatSettings(ctx, srcID, dstID, sections)
+	switch {
+	case err != nil:
+		b.WriteString("❌ خطا در مقایسه: " + err.Error())
+	case len(sections) == 0:
+		b.WriteString("هیچ بخشی انتخاب نشده.")
+	case len(diffs) == 0:
+		b.WriteString("بخش‌های انتخاب‌شده در دو چت یکسان هستند.")
+	default:
+		b.WriteString("تغییرات (فعلی → جدید):\n")
+		for _, df := range diffs {
+			line := fmt.Sprintf("• %s: %s → %s\n", df.Key, truncate(orDash(df.From), 60), truncate(orDash(df.To), 60))
+			if b.Len()+len(line) > 3500 {
+				b.WriteString("…\n")
+				break
+			}
+			b.WriteString(line)
+		}
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, sec := range db.CloneSections {
+		mark := "⬜️"
+		if sel[sec] {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+" "+cloneSectionLabels[sec], fmt.Sprintf("clonet|%d|%d|%s", dstID, srcID, sec)),
+		))
+	}
+	if err == nil && len(diffs) > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ اعمال (%d تغییر)", len(diffs)), fmt.Sprintf("cloneok|%d|%d", dstID, srcID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("clone|%d", dstID)),
+	))
+	a.editOrSendMenu(userID, msgID, b.String(), tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
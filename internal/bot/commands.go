@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/convert"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/scheduler"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+)
+
+// handleGroupCommand answers /price, /p USD EUR, /gold and /<item> (e.g.
+// /usd, /emami) in approved groups that opted in, using the chat's own
+// source, price mode, digits and template.
+func (a *App) handleGroupCommand(msg tgbotapi.Message) {
+	// "/price@OtherBot" is meant for another bot.
+	if at := msg.CommandWithAt(); strings.Contains(at, "@") && !strings.EqualFold(at[strings.Index(at, "@")+1:], a.bot.Self.UserName) {
+		return
+	}
+	cmd := strings.ToLower(msg.Command())
+
+	var ids, unknown []string
+	full := false
+	switch cmd {
+	case "price":
+		full = true
+	case "p":
+		ids, unknown = parseItemArgs(msg.CommandArguments())
+	case "gold":
+	default:
+		it, ok := items.ByID(strings.ToUpper(cmd))
+		if !ok {
+			return
+		}
+		ids = []string{it.ID}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	chatID := msg.Chat.ID
+	ch, err := a.db.GetChat(ctx, chatID)
+	if err != nil || !ch.Approved {
+		return
+	}
+	st, err := a.db.GetChatSettings(ctx, chatID)
+	if err != nil || !st.CommandsEnabled {
+		return
+	}
+	if !a.allowCommand(chatID, time.Duration(st.CommandsCooldownSeconds)*time.Second) {
+		return
+	}
+
+	enabledIDs, _ := a.db.EnabledItemIDs(ctx, chatID)
+	if cmd == "gold" {
+		ids = goldIDs(enabledIDs)
+	}
+
+	var text string
+	switch {
+	case cmd == "p" && len(ids) == 0 && len(unknown) == 0:
+		text = "مثال: /p USD EUR EMAMI"
+	case len(unknown) > 0:
+		text = "❓ آیتم ناشناخته: " + strings.Join(unknown, " ")
+	default:
+		snap, err := a.sources.Get(ctx, sources.Provider(st.SourceProvider), sources.Method(st.SourceMethod))
+		if err != nil {
+			text = "⚠️ قیمت‌ها فعلاً در دسترس نیستند."
+			break
+		}
+		if full {
+			tmpl, err := a.db.GetTemplate(ctx, st.TemplateID)
+			if err != nil {
+				return
+			}
+			lastVals, _ := scheduler.Baseline(ctx, a.db, st, enabledIDs)
+			text = render.BuildMessage(ctx, st, tmpl, enabledIDs, snap, lastVals).Text
+		} else {
+			lastVals, _ := scheduler.Baseline(ctx, a.db, st, ids)
+			text = render.QuoteList(ctx, st, ids, snap, lastVals)
+		}
+		if text == "" {
+			text = "⚠️ قیمتی برای این آیتم‌ها در منبع فعلی پیدا نشد."
+		}
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	reply.ReplyToMessageID = msg.MessageID
+	reply.DisableWebPagePreview = true
+	_, _ = a.out.Send(ctx, chatID, reply)
+}
+
+// allowCommand reports whether chatID may get another command reply now and,
+// if so, starts its cooldown.
+func (a *App) allowCommand(chatID int64, cooldown time.Duration) bool {
+	a.cmdMu.Lock()
+	defer a.cmdMu.Unlock()
+	if last, ok := a.lastCmd[chatID]; ok && time.Since(last) < cooldown {
+		return false
+	}
+	a.lastCmd[chatID] = time.Now()
+	return true
+}
+
+// maxNameWords is the longest item name parseItemArgs tries ("طلای 18 عیار").
+const maxNameWords = 4
+
+// parseItemArgs maps "USD eur سکه امامی"-style arguments to item IDs.
+// Arguments may be IDs (any case), exact Persian names (several words are
+// matched as one name, longest first) or the converter's common names.
+func parseItemArgs(args string) (ids, unknown []string) {
+	seen := map[string]bool{}
+	fields := strings.Fields(items.NormalizeName(strings.ReplaceAll(args, ",", " ")))
+	for i := 0; i < len(fields); {
+		n, id := 0, ""
+		for j := min(len(fields), i+maxNameWords); j > i; j-- {
+			if v, ok := convert.Lookup(strings.Join(fields[i:j], " ")); ok {
+				if _, isItem := items.ByID(v); isItem {
+					n, id = j-i, v
+					break
+				}
+			}
+		}
+		if n == 0 {
+			unknown = append(unknown, fields[i])
+			i++
+			continue
+		}
+		i += n
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, unknown
+}
+
+// goldIDs returns the chat's enabled gold items, or all gold items if none is enabled.
+func goldIDs(enabledIDs []string) []string {
+	var ids []string
+	for _, id := range enabledIDs {
+		if it, ok := items.ByID(id); ok && it.Category == items.CategoryGold {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		return ids
+	}
+	for _, it := range items.All {
+		if it.Category == items.CategoryGold {
+			ids = append(ids, it.ID)
+		}
+	}
+	return ids
+}
+
+func (a *App) sendCommandsMenu(userID int64, msgID int, chatID int64) {
+	ctx := context.Background()
+	st, err := a.db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		return
+	}
+	text := fmt.Sprintf("💬 دستورات گروه\n\nفعال: %v\nفاصله مجاز بین پاسخ‌ها: %d ثانیه\n\n/price — پیام کامل با قالب این چت\n/p USD EUR سکه امامی — فقط آیتم‌های نام‌برده (کد یا نام فارسی)\n/usd ، /emami ، ... — یک آیتم\n/gold — طلا\n\n(فقط در گروه‌ها؛ کانال‌ها دستور ندارند.)",
+		st.CommandsEnabled, st.CommandsCooldownSeconds)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔔 روشن/خاموش", fmt.Sprintf("cmdon|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("-10s", fmt.Sprintf("cmdcd|%d|-10", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("+10s", fmt.Sprintf("cmdcd|%d|10", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("+60s", fmt.Sprintf("cmdcd|%d|60", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", chatID)),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) handleCommandsCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 2 {
+		return
+	}
+	chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+	switch parts[0] {
+	case "cmdon":
+		st, err := a.db.GetChatSettings(ctx, chatID)
+		if err != nil { return }
+		_ = a.db.UpdateChatSetting(ctx, chatID, "commands_enabled", !st.CommandsEnabled)
+	case "cmdcd":
+		if len(parts) < 3 { return }
+		st, err := a.db.GetChatSettings(ctx, chatID)
+		if err != nil { return }
+		delta, _ := strconv.Atoi(parts[2])
+		cd := st.CommandsCooldownSeconds + delta
+		if cd < 5 { cd = 5 }
+		_ = a.db.UpdateChatSetting(ctx, chatID, "commands_cooldown_seconds", cd)
+	}
+	a.sendCommandsMenu(userID, msgID, chatID)
+}
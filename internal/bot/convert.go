@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/convert"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+)
+
+// conversionText prices a parsed request with the given source.
+func (a *App) conversionText(ctx context.Context, req convert.Request, provider sources.Provider, method sources.Method, digits string) (string, error) {
+	snap, err := a.sources.Get(ctx, provider, method)
+	if err != nil {
+		return "", err
+	}
+	res, err := convert.Convert(snap, req)
+	if err != nil {
+		return "", err
+	}
+	return convert.Text(res, digits), nil
+}
+
+// replyPrivateConversion answers a private message like "۱۰۰ درهم" using
+// the global default source. It reports whether msg was a conversion.
+func (a *App) replyPrivateConversion(ctx context.Context, msg tgbotapi.Message) bool {
+	req, ok := convert.Parse(msg.Text)
+	if !ok {
+		return false
+	}
+	provider, method := a.defaultSource(ctx)
+	text, err := a.conversionText(ctx, req, provider, method, "en")
+	if err != nil {
+		text = "⚠️ تبدیل ممکن نیست: " + err.Error()
+	}
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyToMessageID = msg.MessageID
+	_, _ = a.bot.Send(reply)
+	return true
+}
+
+// handleGroupConversion answers conversion requests in approved groups that
+// enabled commands, with the chat's source and digits and the command rate limit.
+func (a *App) handleGroupConversion(msg tgbotapi.Message) {
+	req, ok := convert.Parse(msg.Text)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	chatID := msg.Chat.ID
+	ch, err := a.db.GetChat(ctx, chatID)
+	if err != nil || !ch.Approved {
+		return
+	}
+	st, err := a.db.GetChatSettings(ctx, chatID)
+	if err != nil || !st.CommandsEnabled {
+		return
+	}
+	if !a.allowCommand(chatID, time.Duration(st.CommandsCooldownSeconds)*time.Second) {
+		return
+	}
+	text, err := a.conversionText(ctx, req, sources.Provider(st.SourceProvider), sources.Method(st.SourceMethod), st.Digits)
+	if err != nil {
+		return
+	}
+	reply := tgbotapi.NewMessage(chatID, text)
+	reply.ReplyToMessageID = msg.MessageID
+	_, _ = a.out.Send(ctx, chatID, reply)
+}
@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/scheduler"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+var digestPeriodDays = map[string]string{
+	"daily":   "هر روز",
+	"weekly":  "جمعه‌ها",
+	"monthly": "آخرین روز ماه",
+}
+
+// handleDigestCallback serves the digest menus. Callback data is
+// action|chatID[|period[|arg]].
+func (a *App) handleDigestCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 2 {
+		return
+	}
+	chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+	if parts[0] == "digests" {
+		a.sendDigestsMenu(userID, msgID, chatID)
+		return
+	}
+	if len(parts) < 3 || !validDigestPeriod(parts[2]) {
+		return
+	}
+	period := parts[2]
+
+	switch parts[0] {
+	case "dg":
+		a.sendDigestMenu(userID, msgID, chatID, period)
+	case "dgon":
+		dg, err := a.db.GetDigest(ctx, chatID, period)
+		if err != nil { return }
+		_ = a.db.UpdateDigest(ctx, chatID, period, "enabled", !dg.Enabled)
+		a.sendDigestMenu(userID, msgID, chatID, period)
+	case "dgtime":
+		// dgtime|chatID|period|deltaMinutes
+		if len(parts) < 4 { return }
+		dg, err := a.db.GetDigest(ctx, chatID, period)
+		if err != nil { return }
+		delta, _ := strconv.Atoi(parts[3])
+		cur, ok := utils.ParseHHMM(dg.AtTime)
+		if !ok { cur = 23 * 60 }
+		cur = ((cur+delta)%(24*60) + 24*60) % (24 * 60)
+		_ = a.db.UpdateDigest(ctx, chatID, period, "at_time", utils.FormatHHMM(cur))
+		a.sendDigestMenu(userID, msgID, chatID, period)
+	case "dgtmpl":
+		s := a.ensureSession(userID)
+		s.SelectedChatID = chatID
+		s.DigestPeriod = period
+		s.Await = AwaitDigestTemplate
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "متن خلاصه را بفرستید.\n\nجایگزین‌ها: {PERIOD} {DATE} {FROM} {TO} {ITEMS}\n\nمتن پیش‌فرض:\n"+render.DefaultDigestTemplate))
+	case "dgtmplrst":
+		_ = a.db.UpdateDigest(ctx, chatID, period, "template", "")
+		a.sendDigestMenu(userID, msgID, chatID, period)
+	case "dgprev":
+		st, err := a.db.GetChatSettings(ctx, chatID)
+		if err != nil { return }
+		dg, err := a.db.GetDigest(ctx, chatID, period)
+		if err != nil { return }
+		text, err := scheduler.BuildDigest(ctx, a.db, a.sources, st, dg, time.Now())
+		if err != nil {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ساخت خلاصه ناموفق: "+err.Error()))
+			return
+		}
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, text))
+	}
+}
+
+func validDigestPeriod(p string) bool {
+	for _, v := range db.DigestPeriods {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) sendDigestsMenu(userID int64, msgID int, chatID int64) {
+	ctx := context.Background()
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, p := range db.DigestPeriods {
+		dg, _ := a.db.GetDigest(ctx, chatID, p)
+		mark := "🔕"
+		if dg.Enabled {
+			mark = "✅"
+		}
+		label := fmt.Sprintf("%s خلاصه %s (%s %s)", mark, render.DigestPeriodName(p), digestPeriodDays[p], dg.AtTime)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("dg|%d|%s", chatID, p)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", chatID)),
+	))
+	text := "📊 خلاصه‌های بازار\n\nپیام جداگانه با قیمت باز/بسته/بیشترین/کمترین و درصد تغییر هر آیتم فعال در طول دوره.\nهفته از شنبه شروع و جمعه ارسال می‌شود؛ ماه بر اساس تقویم شمسی است."
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) sendDigestMenu(userID int64, msgID int, chatID int64, period string) {
+	ctx := context.Background()
+	dg, err := a.db.GetDigest(ctx, chatID, period)
+	if err != nil {
+		return
+	}
+	tmpl := "پیش‌فرض"
+	if strings.TrimSpace(dg.Template) != "" {
+		tmpl = "سفارشی"
+	}
+	text := fmt.Sprintf("📊 خلاصه %s\n\nفعال: %v\nزمان ارسال: %s ساعت %s (تهران)\nمتن: %s",
+		render.DigestPeriodName(period), dg.Enabled, digestPeriodDays[period], dg.AtTime, tmpl)
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔔 روشن/خاموش", fmt.Sprintf("dgon|%d|%s", chatID, period)),
+			tgbotapi.NewInlineKeyboardButtonData("👁 پیش‌نمایش", fmt.Sprintf("dgprev|%d|%s", chatID, period)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("-1h", fmt.Sprintf("dgtime|%d|%s|-60", chatID, period)),
+			tgbotapi.NewInlineKeyboardButtonData("-15m", fmt.Sprintf("dgtime|%d|%s|-15", chatID, period)),
+			tgbotapi.NewInlineKeyboardButtonData("+15m", fmt.Sprintf("dgtime|%d|%s|15", chatID, period)),
+			tgbotapi.NewInlineKeyboardButtonData("+1h", fmt.Sprintf("dgtime|%d|%s|60", chatID, period)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ متن خلاصه", fmt.Sprintf("dgtmpl|%d|%s", chatID, period)),
+			tgbotapi.NewInlineKeyboardButtonData("↩️ متن پیش‌فرض", fmt.Sprintf("dgtmplrst|%d|%s", chatID, period)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("digests|%d", chatID)),
+		),
+	}
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) onDigestTemplateMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	body := strings.TrimSpace(msg.Text)
+	if body == "" {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "متن خالی است. لطفاً متن را بفرستید."))
+		return
+	}
+	chatID, period := sess.SelectedChatID, sess.DigestPeriod
+	a.clearAwait(userID)
+	if err := a.db.UpdateDigest(ctx, chatID, period, "template", body); err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ذخیره ناموفق: "+err.Error()))
+		return
+	}
+	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ متن خلاصه ذخیره شد."))
+	a.sendDigestMenu(userID, 0, chatID, period)
+}
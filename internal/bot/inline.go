@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/convert"
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+const (
+	inlineCacheTTL  = 30 * time.Second
+	inlineMaxResult = 10
+)
+
+type inlineCacheEntry struct {
+	results []interface{}
+	at      time.Time
+}
+
+// defaultSource is the global provider/method used outside chat settings
+// (inline queries, private conversions).
+func (a *App) defaultSource(ctx context.Context) (sources.Provider, sources.Method) {
+	p, m := a.db.DefaultSource(ctx)
+	return sources.Provider(p), sources.Method(m)
+}
+
+// handleInlineQuery answers "@bot usd", "@bot دلار" or "@bot usd 100" with
+// one article per matching item, plus a conversion when an amount is given.
+func (a *App) handleInlineQuery(q tgbotapi.InlineQuery) {
+	key := items.NormalizeName(utils.NormalizeDigits(q.Query))
+
+	a.inlineMu.Lock()
+	ce, ok := a.inlineCache[key]
+	a.inlineMu.Unlock()
+	results := ce.results
+	if !ok || time.Since(ce.at) >= inlineCacheTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var err error
+		results, err = a.inlineResults(ctx, key)
+		cancel()
+		if err != nil {
+			log.Printf("inline query %q: %v", q.Query, err)
+			results = []interface{}{}
+		} else {
+			a.inlineMu.Lock()
+			a.pruneInlineCache()
+			a.inlineCache[key] = inlineCacheEntry{results: results, at: time.Now()}
+			a.inlineMu.Unlock()
+		}
+	}
+
+	_, _ = a.bot.Request(tgbotapi.InlineConfig{
+		InlineQueryID: q.ID,
+		Results:       results,
+		CacheTime:     int(inlineCacheTTL / time.Second),
+	})
+}
+
+// pruneInlineCache drops expired entries. Callers hold inlineMu.
+func (a *App) pruneInlineCache() {
+	for k, e := range a.inlineCache {
+		if time.Since(e.at) >= inlineCacheTTL {
+			delete(a.inlineCache, k)
+		}
+	}
+}
+
+func (a *App) inlineResults(ctx context.Context, query string) ([]interface{}, error) {
+	amount, hasAmount := 0.0, false
+	var words []string
+	for _, f := range strings.Fields(query) {
+		if n, ok := utils.ParseNumber(f); ok && !hasAmount {
+			amount, hasAmount = n, true
+			continue
+		}
+		words = append(words, f)
+	}
+
+	provider, method := a.defaultSource(ctx)
+	snap, err := a.sources.Get(ctx, provider, method)
+	if err != nil {
+		return nil, err
+	}
+	st := db.ChatSettings{SourceProvider: string(provider), SourceMethod: string(method), PriceMode: "both", Digits: "en"}
+
+	results := []interface{}{}
+	var matches []items.Item
+	if hasAmount && len(words) > 0 {
+		// "usd 100" and "100 usd to eur" both become a conversion.
+		if req, ok := convert.Parse(strconv.FormatFloat(amount, 'f', -1, 64) + " " + strings.Join(words, " ")); ok {
+			if res, err := convert.Convert(snap, req); err == nil {
+				text := convert.Text(res, "en")
+				lines := strings.Split(text, "\n")
+				results = append(results, inlineArticle("conv:"+req.From+":"+req.To, lines[0], lines[1], text))
+			}
+			if it, ok := items.ByID(req.From); ok {
+				matches = []items.Item{it}
+			}
+		}
+	}
+	if matches == nil {
+		if len(words) == 0 {
+			for _, id := range items.Defaults() {
+				if it, ok := items.ByID(id); ok {
+					matches = append(matches, it)
+				}
+			}
+		} else {
+			matches = items.Search(strings.Join(words, " "))
+		}
+	}
+
+	for _, it := range matches {
+		if len(results) >= inlineMaxResult {
+			break
+		}
+		text := render.QuoteList(ctx, st, []string{it.ID}, snap, nil)
+		if text == "" {
+			continue
+		}
+		desc := strings.SplitN(text, "\n", 2)[0]
+		results = append(results, inlineArticle("item:"+it.ID, it.Emoji+" "+it.NameFa, desc, text))
+	}
+	return results, nil
+}
+
+func inlineArticle(id, title, desc, text string) tgbotapi.InlineQueryResultArticle {
+	art := tgbotapi.NewInlineQueryResultArticle(id, title, text)
+	art.Description = desc
+	return art
+}
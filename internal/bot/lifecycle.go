@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+var problemLabels = map[string]string{
+	db.ProblemLeft:       "ربات از چت خارج شده",
+	db.ProblemKicked:     "ربات از چت اخراج شده",
+	db.ProblemRestricted: "ربات اجازه ارسال پیام ندارد",
+	db.ProblemNoRights:   "ربات ادمین کانال نیست یا اجازه ارسال ندارد",
+}
+
+func problemLabel(p string) string {
+	if l, ok := problemLabels[p]; ok {
+		return l
+	}
+	return p
+}
+
+// botProblem derives the chat problem from the bot's membership in a chat
+// of type typ ("" if the bot can post).
+func botProblem(typ string, m tgbotapi.ChatMember) string {
+	switch m.Status {
+	case "left":
+		return db.ProblemLeft
+	case "kicked":
+		return db.ProblemKicked
+	case "restricted":
+		if !m.CanSendMessages {
+			return db.ProblemRestricted
+		}
+	case "member":
+		if typ == "channel" {
+			return db.ProblemNoRights
+		}
+	case "administrator":
+		if typ == "channel" && !m.CanPostMessages {
+			return db.ProblemNoRights
+		}
+	}
+	return ""
+}
+
+// onBotStatusChange handles my_chat_member updates other than being added:
+// removal, demotion, restriction and their reversal.
+func (a *App) onBotStatusChange(m tgbotapi.ChatMemberUpdated) {
+	ctx := context.Background()
+	ch, err := a.db.GetChat(ctx, m.Chat.ID)
+	if err != nil {
+		// Not a chat we know about.
+		return
+	}
+	problem := botProblem(m.Chat.Type, m.NewChatMember)
+	if problem == ch.Problem {
+		return
+	}
+	if err := a.db.SetChatProblem(ctx, ch.ChatID, problem); err != nil {
+		log.Printf("[bot] set chat problem %d: %v", ch.ChatID, err)
+		return
+	}
+	if !ch.Approved {
+		// Pending or denied (e.g. we just left it): nothing is posted there anyway.
+		return
+	}
+
+	var text string
+	if problem == "" {
+		text = fmt.Sprintf("✅ مشکل چت «%s» (%d) برطرف شد و ارسال‌ها ادامه پیدا می‌کند.", ch.Title, ch.ChatID)
+	} else {
+		text = fmt.Sprintf("⚠️ چت «%s» (%d): %s.\nتا رفع مشکل، ارسالی به این چت انجام نمی‌شود.", ch.Title, ch.ChatID, problemLabel(problem))
+	}
+	if m.From.ID != 0 && m.From.ID != a.bot.Self.ID {
+		text += fmt.Sprintf("\nتوسط: %s (%d)", displayName(m.From), m.From.ID)
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚙️ تنظیمات", fmt.Sprintf("chat|%d", ch.ChatID)),
+			tgbotapi.NewInlineKeyboardButtonData("⚠️ چت‌های مشکل‌دار", "probchats"),
+		),
+	)
+	a.NotifyAdmins(ctx, text, &kb)
+}
+
+// migrateChat moves a group upgraded to a supergroup to its new chat ID.
+func (a *App) migrateChat(oldID, newID int64) {
+	ctx := context.Background()
+	moved, err := a.db.MigrateChat(ctx, oldID, newID)
+	if err != nil {
+		log.Printf("[bot] migrate chat %d -> %d: %v", oldID, newID, err)
+		return
+	}
+	if !moved {
+		return
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚙️ تنظیمات", fmt.Sprintf("chat|%d", newID)),
+		),
+	)
+	a.NotifyAdmins(ctx, fmt.Sprintf("🔀 گروه %d به سوپرگروه %d تبدیل شد؛ تنظیمات، آیتم‌ها و هشدارها منتقل شد.", oldID, newID), &kb)
+}
+
+// problemChats returns the chats the bot can't post in.
+func problemChats(chats []db.Chat) []db.Chat {
+	var out []db.Chat
+	for _, c := range chats {
+		if c.Problem != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (a *App) sendProblemChatsMenu(userID int64, msgID int) {
+	ctx := context.Background()
+	chats, err := a.visibleChats(ctx, userID)
+	if err != nil {
+		return
+	}
+	probs := problemChats(chats)
+
+	var b strings.Builder
+	b.WriteString("⚠️ چت‌های مشکل‌دار\n\nارسال زمان‌بندی‌شده به این چت‌ها متوقف است تا مشکل رفع شود (وقتی ربات دوباره عضو/ادمین شود خودکار رفع می‌شود).\n")
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, c := range probs {
+		since := "—"
+		if c.ProblemAt.Valid {
+			since = time.Unix(c.ProblemAt.Int64, 0).In(utils.TehranLoc()).Format("2006-01-02 15:04")
+		}
+		b.WriteString(fmt.Sprintf("\n• %s (%d)\n  %s — از %s", c.Title, c.ChatID, problemLabel(c.Problem), since))
+
+		row := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("⚙️ "+truncate(c.Title, 18), fmt.Sprintf("chat|%d", c.ChatID)),
+			tgbotapi.NewInlineKeyboardButtonData("✔️ رفع شد", fmt.Sprintf("probclear|%d", c.ChatID)),
+		}
+		if c.Orphaned() {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("🗑 حذف", fmt.Sprintf("chatforget|%d", c.ChatID)))
+		}
+		rows = append(rows, row)
+	}
+	if len(probs) == 0 {
+		b.WriteString("\n(چت مشکل‌داری نیست.)")
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "chats|0"),
+	))
+	a.editOrSendMenu(userID, msgID, b.String(), tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+// Template media collect modes (tmplmd|chatID|templateID|mode).
+const (
+	mediaModeSingle = "s"
+	mediaModeAlbum  = "a"
+	mediaModeRotate = "r"
+)
+
+var mediaTypeLabels = map[string]string{
+	db.MediaPhoto:     "عکس",
+	db.MediaVideo:     "ویدیو",
+	db.MediaAnimation: "گیف",
+	db.MediaDocument:  "فایل",
+	db.MediaAlbum:     "آلبوم",
+	db.MediaRotate:    "تصاویر چرخشی",
+}
+
+// mediaSummary describes a template's media for the manage menu.
+func mediaSummary(t db.Template) string {
+	items := t.Media()
+	if len(items) == 0 {
+		return "ندارد"
+	}
+	if t.MediaType == db.MediaAlbum || t.MediaType == db.MediaRotate {
+		return fmt.Sprintf("%s (%d فایل)", mediaTypeLabels[t.MediaType], len(items))
+	}
+	return mediaTypeLabels[t.MediaType]
+}
+
+// messageMedia returns the file a user sent, typed as it will be posted.
+// GIFs arrive with both Animation and Document set, so Animation wins.
+func messageMedia(msg tgbotapi.Message) (db.MediaItem, bool) {
+	switch {
+	case len(msg.Photo) > 0:
+		return db.MediaItem{Type: db.MediaPhoto, FileID: msg.Photo[len(msg.Photo)-1].FileID}, true
+	case msg.Animation != nil:
+		return db.MediaItem{Type: db.MediaAnimation, FileID: msg.Animation.FileID}, true
+	case msg.Video != nil:
+		return db.MediaItem{Type: db.MediaVideo, FileID: msg.Video.FileID}, true
+	case msg.Document != nil:
+		return db.MediaItem{Type: db.MediaDocument, FileID: msg.Document.FileID}, true
+	}
+	return db.MediaItem{}, false
+}
+
+// handleTemplateMediaCallback serves tmplmedia, tmplmd and tmplmok.
+func (a *App) handleTemplateMediaCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 3 {
+		return
+	}
+	chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+	tid := parts[2]
+	switch parts[0] {
+	case "tmplmedia":
+		// Reopening the menu drops any files collected so far.
+		a.clearAwait(userID)
+		a.sendTemplateMediaMenu(userID, msgID, chatID, tid)
+	case "tmplmd":
+		if len(parts) < 4 {
+			return
+		}
+		var prompt string
+		switch parts[3] {
+		case mediaModeSingle:
+			prompt = "یک عکس، ویدیو، گیف یا فایل برای این قالب ارسال کنید (Caption لازم نیست)."
+		case mediaModeAlbum:
+			prompt = fmt.Sprintf("فایل‌های آلبوم را یکی‌یکی یا با هم ارسال کنید (۲ تا %d عکس/ویدیو، یا فقط فایل). در پایان «ذخیره» را بزنید.", db.MaxAlbumItems)
+		case mediaModeRotate:
+			prompt = fmt.Sprintf("عکس‌های چرخشی را ارسال کنید (۲ تا %d عکس). هر پست جدید عکس بعدی را نشان می‌دهد. در پایان «ذخیره» را بزنید.", db.MaxRotateItems)
+		default:
+			return
+		}
+		s := a.ensureSession(userID)
+		s.SelectedChatID = chatID
+		s.TemplateID = tid
+		s.Await = AwaitSetTemplateMedia
+		s.MediaMode = parts[3]
+		s.MediaItems = nil
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, prompt))
+	case "tmplmok":
+		s := a.ensureSession(userID)
+		if s.Await != AwaitSetTemplateMedia || s.TemplateID != tid {
+			return
+		}
+		mediaType := db.MediaAlbum
+		if s.MediaMode == mediaModeRotate {
+			mediaType = db.MediaRotate
+		}
+		a.saveTemplateMedia(ctx, userID, msgID, chatID, tid, mediaType, s.MediaItems)
+	}
+}
+
+func (a *App) sendTemplateMediaMenu(userID int64, msgID int, chatID int64, tid string) {
+	text := "🖼 مدیای قالب\n\n" +
+		"• یک فایل: عکس، ویدیو، گیف یا فایل همراه متن قالب\n" +
+		fmt.Sprintf("• آلبوم: ۲ تا %d عکس/ویدیو (یا فقط فایل)؛ متن زیر اولین فایل می‌آید\n", db.MaxAlbumItems) +
+		fmt.Sprintf("• تصاویر چرخشی: ۲ تا %d عکس؛ هر پست جدید عکس بعدی را نشان می‌دهد", db.MaxRotateItems)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🖼 یک فایل", fmt.Sprintf("tmplmd|%d|%s|%s", chatID, tid, mediaModeSingle)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗂 آلبوم", fmt.Sprintf("tmplmd|%d|%s|%s", chatID, tid, mediaModeAlbum)),
+			tgbotapi.NewInlineKeyboardButtonData("🔄 تصاویر چرخشی", fmt.Sprintf("tmplmd|%d|%s|%s", chatID, tid, mediaModeRotate)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("tmplm|%d|%s", chatID, tid)),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+// onTemplateMediaMessage takes one file of the template media flow: a
+// single file is saved right away, album/rotate files are collected until
+// the user confirms.
+func (a *App) onTemplateMediaMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	if sess.TemplateID == "" {
+		a.clearAwait(userID)
+		return
+	}
+	item, ok := messageMedia(msg)
+	if !ok {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ این نوع پیام پشتیبانی نمی‌شود. لطفاً عکس، ویدیو، گیف یا فایل ارسال کنید."))
+		return
+	}
+	chatID, tid := sess.SelectedChatID, sess.TemplateID
+	switch sess.MediaMode {
+	case mediaModeAlbum, mediaModeRotate:
+		limit, allowed := db.MaxAlbumItems, item.Type != db.MediaAnimation
+		if sess.MediaMode == mediaModeRotate {
+			limit, allowed = db.MaxRotateItems, item.Type == db.MediaPhoto
+		}
+		if !allowed {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, fmt.Sprintf("❌ %s در این حالت پذیرفته نمی‌شود.", mediaTypeLabels[item.Type])))
+			return
+		}
+		if len(sess.MediaItems) >= limit {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, fmt.Sprintf("❌ حداکثر %d فایل. «ذخیره» را بزنید.", limit)))
+			return
+		}
+		sess.MediaItems = append(sess.MediaItems, item)
+		m := tgbotapi.NewMessage(userID, fmt.Sprintf("📥 %d فایل دریافت شد. فایل بعدی را بفرستید یا ذخیره کنید.", len(sess.MediaItems)))
+		m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ ذخیره", fmt.Sprintf("tmplmok|%d|%s", chatID, tid)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ انصراف", fmt.Sprintf("tmplmedia|%d|%s", chatID, tid)),
+		))
+		_, _ = a.bot.Send(m)
+	default:
+		a.saveTemplateMedia(ctx, userID, msg.MessageID, chatID, tid, item.Type, []db.MediaItem{item})
+	}
+}
+
+func (a *App) saveTemplateMedia(ctx context.Context, userID int64, msgID int, chatID int64, tid, mediaType string, items []db.MediaItem) {
+	if err := a.db.SetTemplateMediaList(ctx, tid, mediaType, items); err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ذخیره مدیا ناموفق: "+err.Error()))
+		return
+	}
+	a.clearAwait(userID)
+	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ مدیا به قالب متصل شد: "+mediaTypeLabels[mediaType]))
+	a.sendTemplateManageMenu(userID, msgID, chatID, tid, "")
+}
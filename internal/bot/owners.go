@@ -0,0 +1,375 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+)
+
+// ownerCallbacks are the self-service callbacks for Telegram admins of
+// approved chats. Each one re-checks that the caller still administers the chat.
+var ownerCallbacks = map[string]bool{
+	"own": true, "ownc": true, "ownit": true, "ownitt": true,
+	"owntp": true, "owntps": true, "ownint": true, "ownints": true,
+}
+
+// ownerAdminsTTL is how long a chat's getChatAdministrators result is reused.
+const ownerAdminsTTL = 5 * time.Minute
+
+type ownerAdminsEntry struct {
+	at     time.Time
+	admins map[int64]bool
+}
+
+var ownerIntervalPresets = []int{1, 2, 3, 5, 10, 15, 30, 60, 120}
+
+// ownerWriteCallbacks change settings, so they check admin status live
+// instead of trusting the cached admin list.
+var ownerWriteCallbacks = map[string]bool{"ownitt": true, "owntps": true, "ownints": true}
+
+// chatAdmins returns the user IDs of chatID's Telegram admins (creator included).
+func (a *App) chatAdmins(chatID int64) (map[int64]bool, error) {
+	a.ownerMu.Lock()
+	e, ok := a.ownerAdmins[chatID]
+	a.ownerMu.Unlock()
+	if ok && time.Since(e.at) < ownerAdminsTTL {
+		return e.admins, nil
+	}
+	members, err := a.bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	if err != nil {
+		return nil, err
+	}
+	admins := map[int64]bool{}
+	for _, m := range members {
+		if m.User != nil && !m.User.IsBot && (m.IsCreator() || m.IsAdministrator()) {
+			admins[m.User.ID] = true
+		}
+	}
+	a.ownerMu.Lock()
+	a.ownerAdmins[chatID] = ownerAdminsEntry{at: time.Now(), admins: admins}
+	a.ownerMu.Unlock()
+	return admins, nil
+}
+
+// isChatAdmin asks Telegram (uncached) whether userID administers chatID. A
+// user who lost admin rights is dropped from the cached list too.
+func (a *App) isChatAdmin(chatID, userID int64) bool {
+	m, err := a.bot.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID}})
+	ok := err == nil && (m.IsCreator() || m.IsAdministrator())
+	if !ok {
+		a.ownerMu.Lock()
+		delete(a.ownerAdmins, chatID)
+		a.ownerMu.Unlock()
+	}
+	return ok
+}
+
+// ownerPolicy returns chatID's policy if userID may self-serve it: the chat is
+// approved, self-service is enabled and userID is currently a Telegram admin
+// there. live skips the admin cache.
+func (a *App) ownerPolicy(ctx context.Context, userID, chatID int64, live bool) (db.OwnerPolicy, bool) {
+	ch, err := a.db.GetChat(ctx, chatID)
+	if err != nil || !ch.Approved {
+		return db.OwnerPolicy{}, false
+	}
+	pol, err := a.db.GetOwnerPolicy(ctx, chatID)
+	if err != nil || !pol.Enabled {
+		return db.OwnerPolicy{}, false
+	}
+	if live {
+		return pol, a.isChatAdmin(chatID, userID)
+	}
+	admins, err := a.chatAdmins(chatID)
+	if err != nil || !admins[userID] {
+		return db.OwnerPolicy{}, false
+	}
+	return pol, true
+}
+
+// ownerTemplates are the templates owners may pick: the built-ins.
+func (a *App) ownerTemplates(ctx context.Context) []db.Template {
+	all, _ := a.db.ListTemplates(ctx)
+	var out []db.Template
+	for _, t := range all {
+		if t.IsBuiltin {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (a *App) handleOwnerCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if parts[0] == "own" {
+		a.sendOwnerChatsMenu(userID, msgID)
+		return
+	}
+	if len(parts) < 2 {
+		return
+	}
+	chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+	pol, ok := a.ownerPolicy(ctx, userID, chatID, ownerWriteCallbacks[parts[0]])
+	if !ok {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "⛔️ مدیریت این چت برای شما در دسترس نیست."))
+		return
+	}
+	switch parts[0] {
+	case "ownc":
+		a.sendOwnerChatMenu(userID, msgID, chatID, pol)
+	case "ownit":
+		page := 0
+		if len(parts) > 2 {
+			page, _ = strconv.Atoi(parts[2])
+		}
+		a.sendOwnerItemsMenu(userID, msgID, chatID, page)
+	case "ownitt":
+		// ownitt|chatID|itemID|page
+		if len(parts) < 4 { return }
+		if _, ok := items.ByID(parts[2]); !ok { return }
+		_, _ = a.db.ToggleChatItem(ctx, chatID, parts[2])
+		page, _ := strconv.Atoi(parts[3])
+		a.sendOwnerItemsMenu(userID, msgID, chatID, page)
+	case "owntp":
+		a.sendOwnerTemplatesMenu(userID, msgID, chatID)
+	case "owntps":
+		if len(parts) < 3 { return }
+		for _, t := range a.ownerTemplates(ctx) {
+			if t.TemplateID == parts[2] {
+				_ = a.db.SetChatTemplate(ctx, chatID, t.TemplateID)
+				break
+			}
+		}
+		a.sendOwnerTemplatesMenu(userID, msgID, chatID)
+	case "ownint":
+		a.sendOwnerIntervalMenu(userID, msgID, chatID, pol)
+	case "ownints":
+		if len(parts) < 3 { return }
+		mins, _ := strconv.Atoi(parts[2])
+		if mins < pol.MinInterval || mins > pol.MaxInterval { return }
+		_ = a.db.UpdateChatSetting(ctx, chatID, "interval_minutes", mins)
+		a.sendOwnerIntervalMenu(userID, msgID, chatID, pol)
+	}
+}
+
+func (a *App) sendOwnerChatsMenu(userID int64, msgID int) {
+	ctx := context.Background()
+	ids, _ := a.db.ListOwnerChatIDs(ctx)
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, id := range ids {
+		admins, err := a.chatAdmins(id)
+		if err != nil || !admins[userID] {
+			continue
+		}
+		ch, err := a.db.GetChat(ctx, id)
+		if err != nil {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📣 "+truncate(ch.Title, 30), fmt.Sprintf("ownc|%d", id)),
+		))
+	}
+	text := "🏷 کانال‌ها و گروه‌های من\n\nچت‌هایی که شما در آن‌ها ادمین تلگرام هستید و مدیریت آن‌ها توسط ادمین ربات باز شده است."
+	if len(rows) == 0 {
+		text += "\n\n(چتی پیدا نشد.)"
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "umenu"),
+	))
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) sendOwnerChatMenu(userID int64, msgID int, chatID int64, pol db.OwnerPolicy) {
+	ctx := context.Background()
+	ch, err := a.db.GetChat(ctx, chatID)
+	if err != nil {
+		return
+	}
+	st, err := a.db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		return
+	}
+	ids, _ := a.db.EnabledItemIDs(ctx, chatID)
+	tmplName := st.TemplateID
+	if t, err := a.db.GetTemplate(ctx, st.TemplateID); err == nil {
+		tmplName = t.Name
+	}
+	text := fmt.Sprintf("🏷 %s\n\nبازه: هر %d دقیقه (مجاز: %d تا %d)\nآیتم‌ها: %d\nقالب: %s",
+		ch.Title, st.IntervalMinutes, pol.MinInterval, pol.MaxInterval, len(ids), tmplName)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💱 آیتم‌ها", fmt.Sprintf("ownit|%d|0", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("🧾 قالب", fmt.Sprintf("owntp|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🕒 بازه", fmt.Sprintf("ownint|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "own"),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) sendOwnerItemsMenu(userID int64, msgID int, chatID int64, page int) {
+	ctx := context.Background()
+	ids, _ := a.db.EnabledItemIDs(ctx, chatID)
+	enabled := map[string]bool{}
+	for _, id := range ids {
+		enabled[id] = true
+	}
+
+	const pageSize = 10
+	start := page * pageSize
+	if start < 0 { start = 0 }
+	if start > len(items.All) { start = len(items.All) }
+	end := start + pageSize
+	if end > len(items.All) { end = len(items.All) }
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, it := range items.All[start:end] {
+		mark := "⬜️"
+		if enabled[it.ID] {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %s %s", mark, it.Emoji, truncate(it.NameFa, 20)), fmt.Sprintf("ownitt|%d|%s|%d", chatID, it.ID, page)),
+		))
+	}
+	nav := []tgbotapi.InlineKeyboardButton{}
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ قبلی", fmt.Sprintf("ownit|%d|%d", chatID, page-1)))
+	}
+	if end < len(items.All) {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("بعدی ➡️", fmt.Sprintf("ownit|%d|%d", chatID, page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("ownc|%d", chatID)),
+	))
+	a.editOrSendMenu(userID, msgID, "✅/⬜️ انتخاب آیتم‌ها", tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) sendOwnerTemplatesMenu(userID int64, msgID int, chatID int64) {
+	ctx := context.Background()
+	st, _ := a.db.GetChatSettings(ctx, chatID)
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, t := range a.ownerTemplates(ctx) {
+		mark := "▫️"
+		if t.TemplateID == st.TemplateID {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+" "+truncate(t.Name, 28), fmt.Sprintf("owntps|%d|%s", chatID, t.TemplateID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("ownc|%d", chatID)),
+	))
+	a.editOrSendMenu(userID, msgID, "🧾 انتخاب قالب", tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+// ownerIntervalChoices are the presets within pol's bounds plus the bounds
+// themselves, so the menu is never empty.
+func ownerIntervalChoices(pol db.OwnerPolicy) []int {
+	set := map[int]bool{pol.MinInterval: true, pol.MaxInterval: true}
+	for _, p := range ownerIntervalPresets {
+		if p >= pol.MinInterval && p <= pol.MaxInterval {
+			set[p] = true
+		}
+	}
+	out := make([]int, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func (a *App) sendOwnerIntervalMenu(userID int64, msgID int, chatID int64, pol db.OwnerPolicy) {
+	st, _ := a.db.GetChatSettings(context.Background(), chatID)
+	text := fmt.Sprintf("🕒 بازه بروزرسانی\n\nحالت فعلی: هر %d دقیقه\nمجاز: %d تا %d دقیقه", st.IntervalMinutes, pol.MinInterval, pol.MaxInterval)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	row := []tgbotapi.InlineKeyboardButton{}
+	for _, p := range ownerIntervalChoices(pol) {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%dm", p), fmt.Sprintf("ownints|%d|%d", chatID, p)))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = []tgbotapi.InlineKeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("ownc|%d", chatID)),
+	))
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+// sendOwnerPolicyMenu is the bot-admin side: whether the chat's own admins may
+// self-serve, and the interval bounds they are held to.
+func (a *App) sendOwnerPolicyMenu(userID int64, msgID int, chatID int64) {
+	pol, err := a.db.GetOwnerPolicy(context.Background(), chatID)
+	if err != nil {
+		return
+	}
+	text := fmt.Sprintf("🏷 مدیریت توسط ادمین‌های چت\n\nفعال: %v\nبازه مجاز: %d تا %d دقیقه\n\nادمین‌های تلگرامی این چت (پس از تایید) می‌توانند از پیوی ربات فقط آیتم‌ها، قالب (از قالب‌های پیش‌فرض) و بازه را در این محدوده تغییر دهند.",
+		pol.Enabled, pol.MinInterval, pol.MaxInterval)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔔 روشن/خاموش", fmt.Sprintf("opolon|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Min -5", fmt.Sprintf("opoladj|%d|min|-5", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("Min +5", fmt.Sprintf("opoladj|%d|min|5", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Max -30", fmt.Sprintf("opoladj|%d|max|-30", chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("Max +30", fmt.Sprintf("opoladj|%d|max|30", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", chatID)),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) handleOwnerPolicyCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 2 {
+		return
+	}
+	chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+	switch parts[0] {
+	case "opolon":
+		pol, err := a.db.GetOwnerPolicy(ctx, chatID)
+		if err != nil { return }
+		_ = a.db.UpdateOwnerPolicy(ctx, chatID, "enabled", !pol.Enabled)
+	case "opoladj":
+		// opoladj|chatID|min/max|delta
+		if len(parts) < 4 { return }
+		pol, err := a.db.GetOwnerPolicy(ctx, chatID)
+		if err != nil { return }
+		delta, _ := strconv.Atoi(parts[3])
+		switch parts[2] {
+		case "min":
+			v := pol.MinInterval + delta
+			if v < 1 { v = 1 }
+			if v > pol.MaxInterval { v = pol.MaxInterval }
+			_ = a.db.UpdateOwnerPolicy(ctx, chatID, "min_interval", v)
+		case "max":
+			v := pol.MaxInterval + delta
+			if v > 120 { v = 120 }
+			if v < pol.MinInterval { v = pol.MinInterval }
+			_ = a.db.UpdateOwnerPolicy(ctx, chatID, "max_interval", v)
+		}
+	}
+	a.sendOwnerPolicyMenu(userID, msgID, chatID)
+}
@@ -0,0 +1,270 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+// handleProfileCallback serves the global profiles menu (profiles, prof*,
+// profsel*) and the per-chat profile menu (cprof*).
+func (a *App) handleProfileCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if parts[0] == "profiles" {
+		a.sendProfilesMenu(userID, msgID)
+		return
+	}
+	if len(parts) < 2 {
+		return
+	}
+	id, _ := strconv.ParseInt(parts[1], 10, 64)
+	switch parts[0] {
+	case "prof":
+		a.sendProfileMenu(userID, msgID, id)
+	case "profdel":
+		_ = a.db.DeleteProfile(ctx, id)
+		a.sendProfilesMenu(userID, msgID)
+	case "profsel":
+		a.ensureSession(userID).ProfileSel = map[int64]bool{}
+		a.sendProfileSelectMenu(userID, msgID, id)
+	case "profselt":
+		// profselt|profileID|chatID
+		if len(parts) < 3 { return }
+		chatID, _ := strconv.ParseInt(parts[2], 10, 64)
+		s := a.ensureSession(userID)
+		if s.ProfileSel == nil {
+			s.ProfileSel = map[int64]bool{}
+		}
+		s.ProfileSel[chatID] = !s.ProfileSel[chatID]
+		a.sendProfileSelectMenu(userID, msgID, id)
+	case "profselall":
+		chats, _ := a.db.ListChats(ctx)
+		s := a.ensureSession(userID)
+		s.ProfileSel = map[int64]bool{}
+		for _, c := range chats {
+			if c.Approved {
+				s.ProfileSel[c.ChatID] = true
+			}
+		}
+		a.sendProfileSelectMenu(userID, msgID, id)
+	case "profapply":
+		// profapply|profileID|linked
+		if len(parts) < 3 { return }
+		linked := parts[2] == "1"
+		s := a.ensureSession(userID)
+		ok, failed := 0, []string{}
+		for chatID, sel := range s.ProfileSel {
+			if !sel {
+				continue
+			}
+			if err := a.db.ApplyProfile(ctx, chatID, id, linked); err != nil {
+				failed = append(failed, fmt.Sprintf("%d: %v", chatID, err))
+				continue
+			}
+			ok++
+		}
+		s.ProfileSel = nil
+		text := fmt.Sprintf("✅ پروفایل روی %d چت اعمال شد.", ok)
+		if len(failed) > 0 {
+			text += "\n\n❌ ناموفق:\n" + strings.Join(failed, "\n")
+		}
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, text))
+		a.sendProfileMenu(userID, msgID, id)
+
+	case "cprof":
+		a.sendChatProfileMenu(userID, msgID, id)
+	case "cprofset":
+		// cprofset|chatID|profileID
+		if len(parts) < 3 { return }
+		pid, _ := strconv.ParseInt(parts[2], 10, 64)
+		if err := a.db.ApplyProfile(ctx, id, pid, false); err != nil {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ اعمال پروفایل ناموفق: "+err.Error()))
+			return
+		}
+		a.sendChatProfileMenu(userID, msgID, id)
+	case "cproflink":
+		cp, ok, err := a.db.GetChatProfile(ctx, id)
+		if err != nil || !ok { return }
+		_ = a.db.SetChatProfileLinked(ctx, id, !cp.Linked)
+		a.sendChatProfileMenu(userID, msgID, id)
+	case "cprofrm":
+		_ = a.db.UnassignProfile(ctx, id)
+		a.sendChatProfileMenu(userID, msgID, id)
+	case "cprofnew":
+		s := a.ensureSession(userID)
+		s.SelectedChatID = id
+		s.Await = AwaitProfileName
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "نام پروفایل جدید را بفرستید.\n(منبع، آیتم‌ها و ترتیب، بازه، downtime، تریگرها، قالب و Digits این چت در آن ذخیره می‌شود.)"))
+	case "cprofsave":
+		cp, ok, err := a.db.GetChatProfile(ctx, id)
+		if err != nil || !ok { return }
+		n, failed, err := a.db.UpdateProfileFromChat(ctx, cp.ProfileID, id)
+		if err != nil {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ به‌روزرسانی پروفایل ناموفق: "+err.Error()))
+		} else {
+			text := fmt.Sprintf("✅ پروفایل به‌روزرسانی شد و روی %d چت لینک‌شده اعمال شد.", n)
+			if len(failed) > 0 {
+				lines := make([]string, len(failed))
+				for i, e := range failed {
+					lines[i] = e.Error()
+				}
+				text += "\n\n❌ ناموفق:\n" + strings.Join(lines, "\n")
+			}
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, text))
+		}
+		a.sendChatProfileMenu(userID, msgID, id)
+	}
+}
+
+func (a *App) onProfileNameMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	name := strings.TrimSpace(msg.Text)
+	if name == "" {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "نام پروفایل خالی است. لطفاً یک نام بفرستید."))
+		return
+	}
+	chatID := sess.SelectedChatID
+	a.clearAwait(userID)
+	p, err := a.db.CreateProfileFromChat(ctx, name, chatID, userID)
+	if err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ساخت پروفایل ناموفق (نام تکراری؟): "+err.Error()))
+		return
+	}
+	// The source chat follows its own profile from now on.
+	_ = a.db.ApplyProfile(ctx, chatID, p.ID, true)
+	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ پروفایل ساخته شد: "+p.Name))
+	a.sendChatProfileMenu(userID, 0, chatID)
+}
+
+func profileSummaryText(p db.Profile) string {
+	s := p.Summary()
+	return fmt.Sprintf("منبع: %s (%s)\nبازه: هر %d دقیقه\nآیتم‌ها: %d\nقالب: %s\nDigits: %s",
+		s.SourceProvider, s.SourceMethod, s.IntervalMinutes, s.EnabledItems, s.TemplateID, s.Digits)
+}
+
+func (a *App) sendProfilesMenu(userID int64, msgID int) {
+	profiles, _ := a.db.ListProfiles(context.Background())
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, p := range profiles {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗂 "+truncate(p.Name, 30), fmt.Sprintf("prof|%d", p.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "main"),
+	))
+	text := "🗂 پروفایل‌های تنظیمات\n\nپروفایل مجموعه‌ای از تنظیمات (منبع، آیتم‌ها و ترتیب، بازه، downtime، تریگرها، قالب، Digits) است که می‌توان آن را یکجا روی چند چت اعمال کرد.\n\nبرای ساخت پروفایل: تنظیمات یک چت ← 🗂 پروفایل ← ذخیره به عنوان پروفایل جدید."
+	if len(profiles) == 0 {
+		text += "\n\n(هنوز پروفایلی ساخته نشده.)"
+	}
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) sendProfileMenu(userID int64, msgID int, profileID int64) {
+	ctx := context.Background()
+	p, err := a.db.GetProfile(ctx, profileID)
+	if err != nil {
+		a.sendProfilesMenu(userID, msgID)
+		return
+	}
+	chats, _ := a.db.ProfileChats(ctx, profileID)
+	linked := 0
+	for _, cp := range chats {
+		if cp.Linked {
+			linked++
+		}
+	}
+	text := fmt.Sprintf("🗂 پروفایل: %s\n\n%s\n\nچت‌های متصل: %d (لینک زنده: %d)\n\nچت‌های لینک زنده با هر به‌روزرسانی پروفایل خودکار به‌روز می‌شوند.",
+		p.Name, profileSummaryText(p), len(chats), linked)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📤 اعمال روی چت‌های انتخابی", fmt.Sprintf("profsel|%d", profileID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 حذف پروفایل", fmt.Sprintf("profdel|%d", profileID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "profiles"),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) sendProfileSelectMenu(userID int64, msgID int, profileID int64) {
+	chats, _ := a.db.ListChats(context.Background())
+	sel := a.ensureSession(userID).ProfileSel
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	n := 0
+	for _, c := range chats {
+		mark := "⬜️"
+		if sel[c.ChatID] {
+			mark = "✅"
+			n++
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+" "+truncate(c.Title, 28), fmt.Sprintf("profselt|%d|%d", profileID, c.ChatID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("☑️ همه چت‌های تاییدشده", fmt.Sprintf("profselall|%d", profileID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📤 اعمال یکباره (%d)", n), fmt.Sprintf("profapply|%d|0", profileID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔗 اعمال + لینک (%d)", n), fmt.Sprintf("profapply|%d|1", profileID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("prof|%d", profileID)),
+		),
+	)
+	text := "📤 چت‌هایی که پروفایل روی آن‌ها اعمال شود را انتخاب کنید.\n\n«اعمال یکباره» فقط تنظیمات فعلی را کپی می‌کند؛ «اعمال + لینک» تغییرات بعدی پروفایل را هم خودکار اعمال می‌کند."
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) sendChatProfileMenu(userID int64, msgID int, chatID int64) {
+	ctx := context.Background()
+	cp, assigned, _ := a.db.GetChatProfile(ctx, chatID)
+	profiles, _ := a.db.ListProfiles(ctx)
+
+	text := "🗂 پروفایل این چت\n\nپروفایلی اختصاص داده نشده."
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if assigned {
+		if p, err := a.db.GetProfile(ctx, cp.ProfileID); err == nil {
+			link := "خیر (کپی یکباره)"
+			if cp.Linked {
+				link = "بله — تغییرات پروفایل خودکار اعمال می‌شود"
+			}
+			text = fmt.Sprintf("🗂 پروفایل این چت: %s\nلینک زنده: %s\n\n%s", p.Name, link, profileSummaryText(p))
+			rows = append(rows,
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🔗 لینک زنده روشن/خاموش", fmt.Sprintf("cproflink|%d", chatID)),
+					tgbotapi.NewInlineKeyboardButtonData("✂️ جدا کردن", fmt.Sprintf("cprofrm|%d", chatID)),
+				),
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("📥 به‌روزرسانی پروفایل از این چت", fmt.Sprintf("cprofsave|%d", chatID)),
+				),
+			)
+		}
+	}
+	for _, p := range profiles {
+		if assigned && p.ID == cp.ProfileID {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↪️ اعمال «"+truncate(p.Name, 24)+"»", fmt.Sprintf("cprofset|%d|%d", chatID, p.ID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💾 ذخیره تنظیمات این چت به عنوان پروفایل جدید", fmt.Sprintf("cprofnew|%d", chatID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("chat|%d", chatID)),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
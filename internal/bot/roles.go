@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+// Callbacks outside the per-chat menus. Every other admin callback carries
+// the chat ID in parts[1] and is checked against the caller's chats.
+var (
+	openCallbacks = map[string]bool{"main": true, "chats": true, "probchats": true, "help": true, "noop": true}
+
+	superOnlyCallbacks = map[string]bool{
+		"admins": true, "adminadd": true, "adminrm": true, "adminedit": true,
+		"adminrole": true, "admchats": true, "admchat": true, "dbrestore": true,
+	}
+
+	globalCallbacks = map[string]bool{
+		"globalsrc": true, "defsrc": true, "setbonuser": true, "setbonhash": true, "setnavkey": true,
+		"backup": true, "dbbackup": true, "auditcsv": true, "approve": true, "deny": true, "chatforget": true,
+		"approval": true, "apadm": true, "apdeny": true, "apexp": true, "apallow": true, "apallowclr": true,
+		// Profiles are shared by many chats.
+		"profiles": true, "prof": true, "profdel": true, "profsel": true, "profselt": true, "profselall": true, "profapply": true,
+		"cprof": true, "cprofset": true, "cproflink": true, "cprofrm": true, "cprofnew": true, "cprofsave": true,
+	}
+
+	// Template edits change a template for every chat using it, so chat
+	// managers may only edit templates they created.
+	templateEditCallbacks = map[string]bool{
+		"tmpledit": true, "tmplmedia": true, "tmplclear": true,
+		"tmplren": true, "tmpldel": true, "tmpldelok": true, "tmplrb": true,
+		"tmplmd": true, "tmplmok": true,
+	}
+)
+
+var roleLabels = map[string]string{
+	db.RoleSuper:   "ادمین اصلی",
+	db.RoleAdmin:   "ادمین کل",
+	db.RoleManager: "مدیر چت",
+}
+
+// allowCallback is the permission check run before every admin callback branch.
+func (a *App) allowCallback(ctx context.Context, userID int64, role string, parts []string) bool {
+	switch {
+	case openCallbacks[parts[0]]:
+		return true
+	case superOnlyCallbacks[parts[0]]:
+		return role == db.RoleSuper
+	case role != db.RoleManager:
+		return true
+	case globalCallbacks[parts[0]]:
+		return false
+	}
+
+	// Chat manager: the callback must target one of their chats.
+	if len(parts) < 2 {
+		return false
+	}
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+	if ok, err := a.db.CanManageChat(ctx, userID, chatID); err != nil || !ok {
+		return false
+	}
+	if templateEditCallbacks[parts[0]] {
+		if len(parts) < 3 {
+			return false
+		}
+		tid := parts[2]
+		if parts[0] == "tmplrb" {
+			// tmplrb|chatID|revID targets the revision's template.
+			revID, _ := strconv.ParseInt(parts[2], 10, 64)
+			r, err := a.db.GetTemplateRevision(ctx, revID)
+			if err != nil {
+				return false
+			}
+			tid = r.TemplateID
+		}
+		t, err := a.db.GetTemplate(ctx, tid)
+		return err == nil && !t.IsBuiltin && t.CreatedBy == userID
+	}
+	return true
+}
+
+// visibleChats returns the chats userID may see in the chats menu.
+func (a *App) visibleChats(ctx context.Context, userID int64) ([]db.Chat, error) {
+	chats, err := a.db.ListChats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	role, err := a.db.GetRole(ctx, userID)
+	if err != nil || role != db.RoleManager {
+		return chats, err
+	}
+	ids, err := a.db.ManagedChatIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := map[int64]bool{}
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	out := []db.Chat{}
+	for _, c := range chats {
+		if allowed[c.ChatID] {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (a *App) sendAdminEditMenu(userID int64, msgID int, adminID int64) {
+	ctx := context.Background()
+	role, err := a.db.GetRole(ctx, adminID)
+	if err != nil || role == "" {
+		a.sendAdminsMenu(userID, msgID)
+		return
+	}
+	ids, _ := a.db.ManagedChatIDs(ctx, adminID)
+	text := fmt.Sprintf("👤 ادمین %d\n\nنقش: %s\nچت‌های قابل مدیریت: %d\n\n• ادمین کل: همه چت‌ها و تنظیمات سراسری\n• مدیر چت: فقط چت‌های انتخاب‌شده",
+		adminID, roleLabels[role], len(ids))
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("ادمین کل", fmt.Sprintf("adminrole|%d|%s", adminID, db.RoleAdmin)),
+			tgbotapi.NewInlineKeyboardButtonData("مدیر چت", fmt.Sprintf("adminrole|%d|%s", adminID, db.RoleManager)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📣 انتخاب چت‌ها", fmt.Sprintf("admchats|%d", adminID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ حذف ادمین", fmt.Sprintf("adminrm|%d", adminID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "admins"),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) sendAdminChatsMenu(userID int64, msgID int, adminID int64) {
+	ctx := context.Background()
+	chats, _ := a.db.ListChats(ctx)
+	ids, _ := a.db.ManagedChatIDs(ctx, adminID)
+	granted := map[int64]bool{}
+	for _, id := range ids {
+		granted[id] = true
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, c := range chats {
+		mark := "▫️"
+		if granted[c.ChatID] {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+" "+truncate(c.Title, 28), fmt.Sprintf("admchat|%d|%d", adminID, c.ChatID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("adminedit|%d", adminID)),
+	))
+	text := fmt.Sprintf("📣 چت‌های ادمین %d\n\nمدیر چت فقط چت‌های تیک‌خورده را می‌بیند و تغییر می‌دهد.", adminID)
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+// handleAdminRoleCallback serves adminedit, adminrole, admchats and admchat (super only).
+func (a *App) handleAdminRoleCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 2 {
+		return
+	}
+	adminID, _ := strconv.ParseInt(parts[1], 10, 64)
+	if role, err := a.db.GetRole(ctx, adminID); err != nil || role == "" || role == db.RoleSuper {
+		return
+	}
+	switch parts[0] {
+	case "adminedit":
+		a.sendAdminEditMenu(userID, msgID, adminID)
+	case "adminrole":
+		if len(parts) < 3 || (parts[2] != db.RoleAdmin && parts[2] != db.RoleManager) {
+			return
+		}
+		_ = a.db.SetAdminRole(ctx, adminID, parts[2])
+		a.sendAdminEditMenu(userID, msgID, adminID)
+	case "admchats":
+		a.sendAdminChatsMenu(userID, msgID, adminID)
+	case "admchat":
+		if len(parts) < 3 {
+			return
+		}
+		chatID, _ := strconv.ParseInt(parts[2], 10, 64)
+		ids, _ := a.db.ManagedChatIDs(ctx, adminID)
+		granted := false
+		for _, id := range ids {
+			if id == chatID {
+				granted = true
+			}
+		}
+		_ = a.db.SetChatManager(ctx, adminID, chatID, !granted)
+		a.sendAdminChatsMenu(userID, msgID, adminID)
+	}
+}
@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// handleTemplateManageCallback serves the per-template manager:
+// tmplm|chatID|tid, tmplren|chatID|tid, tmpldup|chatID|tid,
+// tmpldel|chatID|tid, tmpldelok|chatID|tid, and the revision history:
+// tmplhist|chatID|tid, tmplrv|chatID|revID, tmplrvp|chatID|revID and
+// tmplrb|chatID|revID.
+func (a *App) handleTemplateManageCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	if len(parts) < 3 { return }
+	chatID, _ := strconv.ParseInt(parts[1], 10, 64)
+	tid := parts[2]
+	revID, _ := strconv.ParseInt(parts[2], 10, 64)
+	switch parts[0] {
+	case "tmplhist":
+		a.sendTemplateHistoryMenu(userID, msgID, chatID, tid)
+	case "tmplrv":
+		a.sendTemplateRevisionMenu(userID, msgID, chatID, revID)
+	case "tmplrvp":
+		r, err := a.db.GetTemplateRevision(ctx, revID)
+		if err != nil { return }
+		t, err := a.db.GetTemplate(ctx, r.TemplateID)
+		if err != nil { return }
+		t.Name = fmt.Sprintf("%s (نسخه #%d)", t.Name, r.RevID)
+		t.Body, t.MediaType, t.MediaFileID = r.Body, r.MediaType, r.MediaFileID
+		a.sendTemplatePreview(userID, chatID, t)
+	case "tmplrb":
+		r, err := a.db.GetTemplateRevision(ctx, revID)
+		if err != nil { return }
+		if err := a.db.RollbackTemplate(ctx, revID); err != nil {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ بازگردانی ناموفق: "+err.Error()))
+			return
+		}
+		a.sendTemplateManageMenu(userID, msgID, chatID, r.TemplateID, fmt.Sprintf("↩️ قالب به نسخه #%d بازگردانده شد.", r.RevID))
+	case "tmplm":
+		a.sendTemplateManageMenu(userID, msgID, chatID, tid, "")
+	case "tmplren":
+		s := a.ensureSession(userID)
+		s.SelectedChatID = chatID
+		s.TemplateID = tid
+		s.Await = AwaitRenameTemplate
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "نام جدید قالب را بفرستید."))
+	case "tmpldup":
+		src, err := a.db.GetTemplate(ctx, tid)
+		if err != nil { return }
+		t, err := a.db.DuplicateTemplate(ctx, tid, src.Name+" (کپی)", userID)
+		if err != nil {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ کپی قالب ناموفق: "+err.Error()))
+			return
+		}
+		a.sendTemplateManageMenu(userID, msgID, chatID, t.TemplateID, "✅ کپی ساخته شد؛ حالا می‌توانید آن را ویرایش کنید.")
+	case "tmpldel":
+		a.sendTemplateManageMenu(userID, msgID, chatID, tid, "confirm")
+	case "tmpldelok":
+		err := a.db.DeleteTemplate(ctx, tid)
+		switch {
+		case errors.Is(err, db.ErrTemplateInUse), errors.Is(err, db.ErrBuiltinTemplate):
+			a.sendTemplateManageMenu(userID, msgID, chatID, tid, "")
+		case err != nil:
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ حذف قالب ناموفق: "+err.Error()))
+		default:
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "🗑 قالب حذف شد."))
+			a.sendTemplatesMenu(userID, msgID, chatID)
+		}
+	}
+}
+
+// sendTemplateManageMenu shows one template with its actions. note is shown
+// under the details; "confirm" asks to confirm a delete instead.
+func (a *App) sendTemplateManageMenu(userID int64, msgID int, chatID int64, tid, note string) {
+	ctx := context.Background()
+	t, err := a.db.GetTemplate(ctx, tid)
+	if err != nil {
+		a.sendTemplatesMenu(userID, msgID, chatID)
+		return
+	}
+	usedChats, usedProfiles, _ := a.db.TemplateUsage(ctx, tid)
+	role, _ := a.db.GetRole(ctx, userID)
+	// Chat managers may only change templates they created (see allowCallback).
+	canEdit := !t.IsBuiltin && (role != db.RoleManager || t.CreatedBy == userID)
+
+	kind := "سفارشی"
+	if t.IsBuiltin {
+		kind = "پیش‌فرض (فقط خواندنی)"
+	}
+	owner := "—"
+	if t.CreatedBy == userID {
+		owner = "شما"
+	} else if t.CreatedBy != 0 {
+		owner = strconv.FormatInt(t.CreatedBy, 10)
+	}
+	media := mediaSummary(t)
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧾 قالب: %s\nنوع: %s\nسازنده: %s\nمدیا: %s\nاستفاده در %d چت و %d پروفایل\n\n%s", t.Name, kind, owner, media, usedChats, usedProfiles, truncate(t.Body, 1500))
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ انتخاب برای این چت", fmt.Sprintf("tmplset|%d|%s", chatID, tid)),
+			tgbotapi.NewInlineKeyboardButtonData("👁 Preview", fmt.Sprintf("tmplprev|%d|%s", chatID, tid)),
+		),
+	}
+	switch {
+	case note == "confirm" && (usedChats > 0 || usedProfiles > 0):
+		fmt.Fprintf(&b, "\n\n⛔️ این قالب در %d چت و %d پروفایل تنظیمات استفاده می‌شود. ابتدا قالب دیگری برای آن‌ها انتخاب کنید.", usedChats, usedProfiles)
+	case note == "confirm":
+		b.WriteString("\n\n🗑 این قالب برای همیشه حذف شود؟")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 بله، حذف شود", fmt.Sprintf("tmpldelok|%d|%s", chatID, tid)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ خیر", fmt.Sprintf("tmplm|%d|%s", chatID, tid)),
+		))
+	case note != "":
+		b.WriteString("\n\n" + note)
+	}
+	if t.IsBuiltin {
+		b.WriteString("\n\nℹ️ قالب‌های پیش‌فرض قابل ویرایش نیستند؛ برای شخصی‌سازی از آن کپی بگیرید.")
+	}
+
+	if canEdit && note != "confirm" {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✏️ ویرایش متن", fmt.Sprintf("tmpledit|%d|%s", chatID, tid)),
+				tgbotapi.NewInlineKeyboardButtonData("🏷 تغییر نام", fmt.Sprintf("tmplren|%d|%s", chatID, tid)),
+			),
+		)
+		mediaRow := tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🖼 مدیا", fmt.Sprintf("tmplmedia|%d|%s", chatID, tid)),
+		)
+		if t.MediaType != "" {
+			mediaRow = append(mediaRow, tgbotapi.NewInlineKeyboardButtonData("🧹 حذف مدیا", fmt.Sprintf("tmplclear|%d|%s", chatID, tid)))
+		}
+		rows = append(rows, mediaRow, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 حذف قالب", fmt.Sprintf("tmpldel|%d|%s", chatID, tid)),
+		))
+	}
+	if !t.IsBuiltin && note != "confirm" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🕘 تاریخچه نسخه‌ها", fmt.Sprintf("tmplhist|%d|%s", chatID, tid)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📑 ساخت کپی", fmt.Sprintf("tmpldup|%d|%s", chatID, tid)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("tmpl|%d", chatID)),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, b.String(), tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) onTemplateRenameMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	name := strings.TrimSpace(msg.Text)
+	if name == "" {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "نام قالب خالی است. لطفاً یک نام بفرستید."))
+		return
+	}
+	tid, chatID := sess.TemplateID, sess.SelectedChatID
+	a.clearAwait(userID)
+	t, err := a.db.GetTemplate(ctx, tid)
+	if err == nil {
+		err = a.db.UpdateTemplateMeta(ctx, tid, name, t.Description)
+	}
+	if err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ تغییر نام ناموفق: "+err.Error()))
+		return
+	}
+	a.sendTemplateManageMenu(userID, 0, chatID, tid, "✅ نام قالب تغییر کرد.")
+}
+
+// revisionLabel is a one-line summary of a revision for buttons and headers.
+func revisionLabel(r db.TemplateRevision) string {
+	author := "—"
+	if r.CreatedBy != 0 {
+		author = strconv.FormatInt(r.CreatedBy, 10)
+	}
+	label := fmt.Sprintf("#%d • %s • %s", r.RevID, time.Unix(r.CreatedAt, 0).In(utils.TehranLoc()).Format("01-02 15:04"), author)
+	if r.MediaType != "" {
+		label += " • " + r.MediaType
+	}
+	return label
+}
+
+func (a *App) sendTemplateHistoryMenu(userID int64, msgID int, chatID int64, tid string) {
+	ctx := context.Background()
+	t, err := a.db.GetTemplate(ctx, tid)
+	if err != nil {
+		a.sendTemplatesMenu(userID, msgID, chatID)
+		return
+	}
+	revs, _ := a.db.ListTemplateRevisions(ctx, tid, 15)
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for i, r := range revs {
+		label := revisionLabel(r)
+		if i == 0 {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("tmplrv|%d|%d", chatID, r.RevID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("tmplm|%d|%s", chatID, tid)),
+	))
+	text := "🕘 تاریخچه قالب: " + t.Name + "\n\nهر ویرایش متن یا مدیا یک نسخه ذخیره می‌کند (شماره • زمان • ویرایشگر). ✅ نسخه فعلی است.\nیک نسخه را برای پیش‌نمایش یا بازگردانی انتخاب کنید."
+	if len(revs) == 0 {
+		text += "\n\n(این قالب هنوز ویرایش نشده.)"
+	}
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) sendTemplateRevisionMenu(userID int64, msgID int, chatID int64, revID int64) {
+	ctx := context.Background()
+	r, err := a.db.GetTemplateRevision(ctx, revID)
+	if err != nil {
+		a.sendTemplatesMenu(userID, msgID, chatID)
+		return
+	}
+	t, err := a.db.GetTemplate(ctx, r.TemplateID)
+	if err != nil {
+		a.sendTemplatesMenu(userID, msgID, chatID)
+		return
+	}
+	role, _ := a.db.GetRole(ctx, userID)
+	canEdit := !t.IsBuiltin && (role != db.RoleManager || t.CreatedBy == userID)
+	current := t.Body == r.Body && t.MediaType == r.MediaType && t.MediaFileID == r.MediaFileID
+
+	text := fmt.Sprintf("🧾 %s\nنسخه %s\n\n%s", t.Name, revisionLabel(r), truncate(r.Body, 3000))
+	if current {
+		text += "\n\n✅ قالب الآن همین نسخه است."
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👁 Preview", fmt.Sprintf("tmplrvp|%d|%d", chatID, r.RevID)),
+		),
+	}
+	if canEdit && !current {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ بازگردانی به این نسخه", fmt.Sprintf("tmplrb|%d|%d", chatID, r.RevID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", fmt.Sprintf("tmplhist|%d|%s", chatID, t.TemplateID)),
+	))
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// userCallbacks are the personal-mode callbacks any user (not only admins) may use.
+var userCallbacks = map[string]bool{
+	"umenu": true, "usubon": true, "usubtime": true, "usubitems": true, "usubit": true,
+	"ual": true, "ualadd": true, "ualitem": true, "ualkind": true, "ualdel": true,
+}
+
+// handleUserCallback serves the personal subscription and alert menus.
+// Everything is scoped to the calling user.
+func (a *App) handleUserCallback(ctx context.Context, userID int64, msgID int, parts []string) {
+	switch parts[0] {
+	case "umenu":
+		a.sendUserMenu(userID, msgID)
+	case "usubon":
+		sub, err := a.db.GetUserSubscription(ctx, userID)
+		if err != nil { return }
+		_ = a.db.UpdateUserSubscription(ctx, userID, "enabled", !sub.Enabled)
+		a.sendUserMenu(userID, msgID)
+	case "usubtime":
+		// usubtime|deltaMinutes
+		if len(parts) < 2 { return }
+		sub, err := a.db.GetUserSubscription(ctx, userID)
+		if err != nil { return }
+		delta, _ := strconv.Atoi(parts[1])
+		cur, ok := utils.ParseHHMM(sub.AtTime)
+		if !ok { cur = 9 * 60 }
+		cur = ((cur+delta)%(24*60) + 24*60) % (24 * 60)
+		_ = a.db.UpdateUserSubscription(ctx, userID, "at_time", utils.FormatHHMM(cur))
+		a.sendUserMenu(userID, msgID)
+	case "usubitems":
+		a.sendUserItemsMenu(userID, msgID)
+	case "usubit":
+		// usubit|itemID
+		if len(parts) < 2 { return }
+		if _, ok := items.ByID(parts[1]); !ok { return }
+		sub, err := a.db.GetUserSubscription(ctx, userID)
+		if err != nil { return }
+		next := []string{}
+		found := false
+		for _, id := range sub.Items {
+			if id == parts[1] {
+				found = true
+				continue
+			}
+			next = append(next, id)
+		}
+		if !found {
+			next = append(next, parts[1])
+		}
+		if err := a.db.UpdateUserSubscription(ctx, userID, "items", next); err != nil {
+			_, _ = a.bot.Send(tgbotapi.NewMessage(userID, fmt.Sprintf("⚠️ حداکثر %d آیتم.", db.MaxUserSubItems)))
+			return
+		}
+		a.sendUserItemsMenu(userID, msgID)
+	case "ual":
+		a.sendUserAlertsMenu(userID, msgID)
+	case "ualadd":
+		rows := [][]tgbotapi.InlineKeyboardButton{}
+		row := []tgbotapi.InlineKeyboardButton{}
+		for _, it := range items.All {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(it.Emoji+" "+truncate(it.NameFa, 14), "ualitem|"+it.ID))
+			if len(row) == 2 {
+				rows = append(rows, row)
+				row = []tgbotapi.InlineKeyboardButton{}
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "ual")))
+		a.editOrSendMenu(userID, msgID, "🔔 هشدار جدید\n\nآیتم را انتخاب کنید:", tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+	case "ualitem":
+		if len(parts) < 2 { return }
+		it, ok := items.ByID(parts[1])
+		if !ok { return }
+		kb := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("⬆️ بالاتر از", "ualkind|"+it.ID+"|above"),
+				tgbotapi.NewInlineKeyboardButtonData("⬇️ پایین‌تر از", "ualkind|"+it.ID+"|below"),
+			),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "ualadd")),
+		)
+		a.editOrSendMenu(userID, msgID, fmt.Sprintf("🔔 هشدار برای %s %s\n\nکی خبرتان کنیم؟", it.Emoji, it.NameFa), kb)
+	case "ualkind":
+		// ualkind|itemID|kind
+		if len(parts) < 3 || (parts[2] != "above" && parts[2] != "below") { return }
+		if _, ok := items.ByID(parts[1]); !ok { return }
+		s := a.ensureSession(userID)
+		s.AlertItemID = parts[1]
+		s.AlertKind = parts[2]
+		s.Await = AwaitUserAlertLevel
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "قیمت را بفرستید (مثلاً 70000 یا ۷۰٬۰۰۰)."))
+	case "ualdel":
+		if len(parts) < 2 { return }
+		alertID, _ := strconv.ParseInt(parts[1], 10, 64)
+		_ = a.db.DeleteUserAlert(ctx, userID, alertID)
+		a.sendUserAlertsMenu(userID, msgID)
+	}
+}
+
+func (a *App) sendUserMenu(userID int64, msgID int) {
+	ctx := context.Background()
+	sub, _ := a.db.GetUserSubscription(ctx, userID)
+	alerts, _ := a.db.ListUserAlerts(ctx, userID)
+	active := 0
+	for _, al := range alerts {
+		if al.Enabled {
+			active++
+		}
+	}
+	state := "خاموش"
+	if sub.Enabled {
+		state = "روشن"
+	}
+	text := fmt.Sprintf("👤 حالت کاربر\n\n📬 برد روزانه: %s — ساعت %s (تهران)، %d آیتم\n🔔 هشدارهای فعال: %d از %d\n\nبرای تبدیل ارز کافیست بنویسید: ۱۰۰ درهم یا 250 usd to eur",
+		state, sub.AtTime, len(sub.Items), active, db.MaxUserAlerts)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📬 برد روزانه روشن/خاموش", "usubon"),
+			tgbotapi.NewInlineKeyboardButtonData("🧾 آیتم‌ها", "usubitems"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("-1h", "usubtime|-60"),
+			tgbotapi.NewInlineKeyboardButtonData("-15m", "usubtime|-15"),
+			tgbotapi.NewInlineKeyboardButtonData("+15m", "usubtime|15"),
+			tgbotapi.NewInlineKeyboardButtonData("+1h", "usubtime|60"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔔 هشدارهای من", "ual"),
+			tgbotapi.NewInlineKeyboardButtonData("🏷 کانال‌های من", "own"),
+		),
+	)
+	a.editOrSendMenu(userID, msgID, text, kb)
+}
+
+func (a *App) sendUserItemsMenu(userID int64, msgID int) {
+	sub, _ := a.db.GetUserSubscription(context.Background(), userID)
+	selected := map[string]bool{}
+	for _, id := range sub.Items {
+		selected[id] = true
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	row := []tgbotapi.InlineKeyboardButton{}
+	for _, it := range items.All {
+		mark := "▫️"
+		if selected[it.ID] {
+			mark = "✅"
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(mark+" "+truncate(it.NameFa, 14), "usubit|"+it.ID))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = []tgbotapi.InlineKeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "umenu")))
+	text := fmt.Sprintf("🧾 آیتم‌های برد روزانه (حداکثر %d)", db.MaxUserSubItems)
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) sendUserAlertsMenu(userID int64, msgID int) {
+	alerts, _ := a.db.ListUserAlerts(context.Background(), userID)
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, al := range alerts {
+		name := al.ItemID
+		if it, ok := items.ByID(al.ItemID); ok {
+			name = it.Emoji + " " + it.NameFa
+		}
+		cond := "≥"
+		if al.Kind == "below" {
+			cond = "≤"
+		}
+		mark := "🔔"
+		if !al.Enabled {
+			mark = "✔️"
+		}
+		label := fmt.Sprintf("🗑 %s %s %s %s", mark, truncate(name, 16), cond, utils.FormatNumber(al.Level, "", "en"))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("ualdel|%d", al.AlertID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ هشدار جدید", "ualadd")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ بازگشت", "umenu")),
+	)
+	text := fmt.Sprintf("🔔 هشدارهای من\n\nهر هشدار یک‌بار ارسال می‌شود (🔔 فعال، ✔️ ارسال‌شده). برای حذف روی آن بزنید.\nحداکثر %d هشدار فعال؛ فقط %d هشدار ارسال‌شده‌ی آخر نگه داشته می‌شود.", db.MaxUserAlerts, db.MaxFiredUserAlerts)
+	a.editOrSendMenu(userID, msgID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+func (a *App) onUserAlertLevelMessage(ctx context.Context, msg tgbotapi.Message, sess *Session) {
+	userID := msg.From.ID
+	level, ok := utils.ParseNumber(msg.Text)
+	if !ok || level <= 0 {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "عدد معتبر نیست. لطفاً فقط عدد بفرستید."))
+		return
+	}
+	al := db.UserAlert{UserID: userID, ItemID: sess.AlertItemID, Kind: sess.AlertKind, Level: level}
+	a.clearAwait(userID)
+	if _, err := a.db.CreateUserAlert(ctx, al); err != nil {
+		_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "❌ ساخت هشدار ناموفق: "+err.Error()))
+		return
+	}
+	_, _ = a.bot.Send(tgbotapi.NewMessage(userID, "✅ هشدار ساخته شد."))
+	a.sendUserAlertsMenu(userID, 0)
+}
@@ -0,0 +1,245 @@
+// Package convert parses conversion requests such as "۱۰۰ درهم",
+// "250 usd to eur" or "2 سکه امامی" and prices them from a snapshot.
+package convert
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// Pseudo item IDs for the local currency.
+const (
+	Toman = "TOMAN"
+	Rial  = "RIAL"
+)
+
+// aliases maps common names (normalized with items.NormalizeName) to item IDs.
+var aliases = map[string]string{
+	"تومان": Toman, "تومن": Toman, "toman": Toman, "irt": Toman,
+	"ریال": Rial, "rial": Rial, "irr": Rial,
+	"دلار": "USD", "دلار آمریکا": "USD", "دلار امریکا": "USD", "dollar": "USD", "dollars": "USD", "$": "USD",
+	"یورو": "EUR", "euro": "EUR", "euros": "EUR", "€": "EUR",
+	"پوند": "GBP", "pound": "GBP", "£": "GBP",
+	"درهم": "AED", "dirham": "AED", "dirhams": "AED",
+	"لیر": "TRY", "لیر ترکیه": "TRY", "lira": "TRY",
+	"یوان": "CNY", "yuan": "CNY",
+	"روبل": "RUB", "ruble": "RUB",
+	"ین": "JPY", "yen": "JPY",
+	"دینار": "IQD", "دینار عراق": "IQD",
+	"فرانک": "CHF", "franc": "CHF",
+	"سکه": "EMAMI", "امامی": "EMAMI", "سکه امامی": "EMAMI", "سکه جدید": "EMAMI",
+	"بهار": "BAHAR", "بهار آزادی": "BAHAR", "سکه بهار": "BAHAR", "سکه قدیم": "BAHAR",
+	"نیم": "NIM", "نیم سکه": "NIM",
+	"ربع": "ROB", "ربع سکه": "ROB",
+	"گرمی": "GERAMI", "سکه گرمی": "GERAMI",
+	"طلا": "GERAM18", "طلای 18": "GERAM18", "طلا 18": "GERAM18", "گرم طلا": "GERAM18",
+	"طلای 24": "GERAM24", "طلا 24": "GERAM24",
+	"مثقال": "MITHQAL", "مثقال طلا": "MITHQAL",
+	"اونس": "OUNCE", "انس": "OUNCE", "ounce": "OUNCE",
+	"بیت کوین": "BTC", "بیتکوین": "BTC", "bitcoin": "BTC",
+}
+
+// fillers are question words dropped before parsing ("۱۰۰ درهم چند تومان؟").
+var fillers = regexp.MustCompile(`(?:[?؟]|\s(?:چند|چنده|میشه|می شه|میشود|چقدر|است|هست)(?:\s|$))`)
+
+var (
+	// The multiplier must be followed by a space ("2k usd", "2 هزار درهم"),
+	// so units that start with k or m ("2 mithqal", "100 kwd") stay whole.
+	reRequest = regexp.MustCompile(`^([0-9][0-9,]*(?:\.[0-9]+)?)\s*(?:(k|m|هزار|میلیون)\s+)?(.+)$`)
+	reTarget  = regexp.MustCompile(`\s+(?:to|in|به|=|->|=>)\s+`)
+)
+
+// Request is a parsed conversion: Amount of From expressed in To.
+type Request struct {
+	Amount float64
+	From   string // item ID, Toman or Rial
+	To     string
+}
+
+// Parse reads a conversion request. ok is false if text is not one (so
+// ordinary chat messages are ignored). The target defaults to toman.
+func Parse(text string) (Request, bool) {
+	s := items.NormalizeName(utils.NormalizeDigits(text))
+	s = strings.TrimSpace(fillers.ReplaceAllString(" "+s+" ", " "))
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) > 60 {
+		return Request{}, false
+	}
+	m := reRequest.FindStringSubmatch(s)
+	if m == nil {
+		return Request{}, false
+	}
+	amount, ok := utils.ParseNumber(m[1])
+	if !ok || amount <= 0 {
+		return Request{}, false
+	}
+	switch m[2] {
+	case "k", "هزار":
+		amount *= 1_000
+	case "m", "میلیون":
+		amount *= 1_000_000
+	}
+
+	rest := m[3]
+	to := Toman
+	if parts := reTarget.Split(rest, 2); len(parts) == 2 {
+		rest = parts[0]
+		if to, ok = lookup(parts[1]); !ok {
+			return Request{}, false
+		}
+	} else if _, whole := lookup(rest); !whole {
+		// "100 usd eur" / "۱۰۰ درهم تومان": the last word may be the target
+		// (but "2 سکه امامی" is one name).
+		if f := strings.Fields(rest); len(f) > 1 {
+			if t, ok := lookup(f[len(f)-1]); ok {
+				if _, ok := lookup(strings.Join(f[:len(f)-1], " ")); ok {
+					to, rest = t, strings.Join(f[:len(f)-1], " ")
+				}
+			}
+		}
+	}
+	from, ok := lookup(rest)
+	if !ok || from == to {
+		return Request{}, false
+	}
+	return Request{Amount: amount, From: from, To: to}, true
+}
+
+// Lookup resolves a currency name as a user typed it (see lookup).
+func Lookup(name string) (string, bool) {
+	return lookup(items.NormalizeName(utils.NormalizeDigits(name)))
+}
+
+// lookup resolves a currency name: alias, item ID or exact Persian name.
+func lookup(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if id, ok := aliases[name]; ok {
+		return id, true
+	}
+	if it, ok := items.ByID(strings.ToUpper(name)); ok {
+		return it.ID, true
+	}
+	for _, it := range items.All {
+		if items.NormalizeName(it.NameFa) == name {
+			return it.ID, true
+		}
+	}
+	return "", false
+}
+
+// Result is a priced conversion. Sell/Buy are in To; a side is nil when the
+// snapshot lacks that price for either end.
+type Result struct {
+	Request
+	Sell *float64
+	Buy  *float64
+}
+
+var ErrNoPrice = errors.New("no price for this currency in the current source")
+
+// Convert prices r against snap, going through toman so any two items
+// (or toman/rial) can be crossed. USD-quoted items use the USD rate.
+func Convert(snap sources.Snapshot, r Request) (Result, error) {
+	res := Result{Request: r}
+	for _, side := range []string{"sell", "buy"} {
+		from, ok1 := tomanValue(snap, r.From, side)
+		to, ok2 := tomanValue(snap, r.To, side)
+		if !ok1 || !ok2 || to == 0 {
+			continue
+		}
+		v := r.Amount * from / to
+		if side == "sell" {
+			res.Sell = &v
+		} else {
+			res.Buy = &v
+		}
+	}
+	if res.Sell == nil && res.Buy == nil {
+		return res, ErrNoPrice
+	}
+	return res, nil
+}
+
+// tomanValue is the toman price of one unit of id on the given side.
+func tomanValue(snap sources.Snapshot, id, side string) (float64, bool) {
+	switch id {
+	case Toman:
+		return 1, true
+	case Rial:
+		return 0.1, true
+	}
+	q, ok := snap.Quotes[id]
+	if !ok {
+		return 0, false
+	}
+	p := q.Sell
+	if side == "buy" {
+		p = q.Buy
+	}
+	if p == nil {
+		return 0, false
+	}
+	if q.Unit == items.UnitUSD {
+		usd, ok := tomanValue(snap, "USD", side)
+		return *p * usd, ok
+	}
+	return *p, true
+}
+
+// Name returns the display name of an item ID, toman or rial.
+func Name(id string) string {
+	switch id {
+	case Toman:
+		return "تومان"
+	case Rial:
+		return "ریال"
+	}
+	if it, ok := items.ByID(id); ok {
+		return it.NameFa
+	}
+	return id
+}
+
+// Text renders a result for chat replies.
+func Text(res Result, digits string) string {
+	var b strings.Builder
+	b.WriteString("🔁 " + formatAmount(res.Amount, digits) + " " + Name(res.From) + "\n")
+	if res.Sell != nil {
+		b.WriteString("= " + formatAmount(*res.Sell, digits) + " " + Name(res.To))
+		if res.Buy != nil {
+			b.WriteString(" (فروش)")
+		}
+		b.WriteString("\n")
+	}
+	if res.Buy != nil && (res.Sell == nil || *res.Buy != *res.Sell) {
+		b.WriteString("= " + formatAmount(*res.Buy, digits) + " " + Name(res.To) + " (خرید)\n")
+	}
+	dt := utils.JalaliDateTime(utils.NowTehran())
+	if digits == "fa" {
+		dt = utils.ToPersianDigits(dt)
+	}
+	b.WriteString("🕒 " + dt)
+	return b.String()
+}
+
+// formatAmount keeps small amounts readable (0.0012 BTC) and rounds large ones.
+func formatAmount(v float64, digits string) string {
+	if v >= 1000 || v == float64(int64(v)) {
+		return utils.FormatNumber(v, "", digits)
+	}
+	prec := 6
+	if v >= 1 {
+		prec = 2
+	}
+	s := strings.TrimRight(strings.TrimRight(strconv.FormatFloat(v, 'f', prec, 64), "0"), ".")
+	if digits == "fa" {
+		s = utils.ToPersianDigits(s)
+	}
+	return s
+}
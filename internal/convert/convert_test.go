@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want Request
+		ok   bool
+	}{
+		{"100 usd", Request{Amount: 100, From: "USD", To: Toman}, true},
+		{"2k usd", Request{Amount: 2000, From: "USD", To: Toman}, true},
+		{"2 k usd", Request{Amount: 2000, From: "USD", To: Toman}, true},
+		{"1.5m rial", Request{Amount: 1_500_000, From: Rial, To: Toman}, true},
+		{"۲ هزار درهم", Request{Amount: 2000, From: "AED", To: Toman}, true},
+		{"2 mithqal", Request{Amount: 2, From: "MITHQAL", To: Toman}, true},
+		{"100 myr", Request{Amount: 100, From: "MYR", To: Toman}, true},
+		{"100 kwd", Request{Amount: 100, From: "KWD", To: Toman}, true},
+		{"3 kwd to usd", Request{Amount: 3, From: "KWD", To: "USD"}, true},
+		{"2 سکه امامی", Request{Amount: 2, From: "EMAMI", To: Toman}, true},
+		{"100 درهم چند تومان؟", Request{Amount: 100, From: "AED", To: Toman}, true},
+		{"100 usd eur", Request{Amount: 100, From: "USD", To: "EUR"}, true},
+		{"hello", Request{}, false},
+		{"100 toman", Request{}, false},
+		{"2 kilo", Request{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.text)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	snap := sources.Snapshot{Quotes: map[string]sources.Quote{
+		"USD":     {Sell: f(50_000), Buy: f(49_000), Unit: "toman"},
+		"KWD":     {Sell: f(160_000), Unit: "toman"},
+		"MITHQAL": {Sell: f(20_000_000), Unit: "toman"},
+		"OUNCE":   {Sell: f(2_000), Unit: "usd"},
+	}}
+	tests := []struct {
+		text     string
+		sell     float64
+		buy      *float64
+		wantFail bool
+	}{
+		{"2 mithqal", 40_000_000, nil, false},
+		{"100 kwd", 16_000_000, nil, false},
+		{"3 kwd to usd", 9.6, nil, false},
+		{"1 ounce", 100_000_000, nil, false},
+		{"10 usd to rial", 5_000_000, f(4_900_000), false},
+		{"100 myr", 0, nil, true},
+	}
+	for _, tt := range tests {
+		r, ok := Parse(tt.text)
+		if !ok {
+			t.Fatalf("Parse(%q) failed", tt.text)
+		}
+		res, err := Convert(snap, r)
+		if tt.wantFail {
+			if err != ErrNoPrice {
+				t.Errorf("Convert(%q) err = %v, want ErrNoPrice", tt.text, err)
+			}
+			continue
+		}
+		if err != nil || res.Sell == nil || *res.Sell != tt.sell {
+			t.Errorf("Convert(%q) sell = %v, %v; want %v", tt.text, res.Sell, err, tt.sell)
+		}
+		if (tt.buy == nil) != (res.Buy == nil) || (tt.buy != nil && *res.Buy != *tt.buy) {
+			t.Errorf("Convert(%q) buy = %v; want %v", tt.text, res.Buy, tt.buy)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AlertRule is a per-chat price alert posted as its own message.
+// Kind is "above"/"below" (Level is a price) or "move" (Level is a percent
+// move within WindowMinutes).
+type AlertRule struct {
+	RuleID          int64
+	ChatID          int64
+	ItemID          string
+	Kind            string
+	Level           float64
+	WindowMinutes   int
+	CooldownMinutes int
+	Template        string // empty = default text for Kind
+	Pin             bool
+	Enabled         bool
+
+	LastValue   sql.NullFloat64
+	LastFiredAt sql.NullInt64
+}
+
+const alertRuleCols = `rule_id,chat_id,item_id,kind,level,window_minutes,cooldown_minutes,template,pin,enabled,last_value,last_fired_at`
+
+func scanAlertRule(sc interface{ Scan(...any) error }) (AlertRule, error) {
+	var r AlertRule
+	var pin, enabled int
+	err := sc.Scan(&r.RuleID, &r.ChatID, &r.ItemID, &r.Kind, &r.Level, &r.WindowMinutes, &r.CooldownMinutes,
+		&r.Template, &pin, &enabled, &r.LastValue, &r.LastFiredAt)
+	r.Pin = pin == 1
+	r.Enabled = enabled == 1
+	return r, err
+}
+
+func (d *DB) queryAlertRules(ctx context.Context, q string, args ...any) ([]AlertRule, error) {
+	rows, err := d.sql.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AlertRule
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) ListAlertRules(ctx context.Context, chatID int64) ([]AlertRule, error) {
+	return d.queryAlertRules(ctx, `SELECT `+alertRuleCols+` FROM alert_rules WHERE chat_id=? ORDER BY rule_id ASC`, chatID)
+}
+
+// ListEnabledAlertRules returns enabled rules of all chats (for the scheduler).
+func (d *DB) ListEnabledAlertRules(ctx context.Context) ([]AlertRule, error) {
+	return d.queryAlertRules(ctx, `SELECT `+alertRuleCols+` FROM alert_rules WHERE enabled=1 ORDER BY chat_id, rule_id`)
+}
+
+func (d *DB) GetAlertRule(ctx context.Context, ruleID int64) (AlertRule, error) {
+	return scanAlertRule(d.sql.QueryRowContext(ctx, `SELECT `+alertRuleCols+` FROM alert_rules WHERE rule_id=?`, ruleID))
+}
+
+func (d *DB) CreateAlertRule(ctx context.Context, r AlertRule) (int64, error) {
+	res, err := d.sql.ExecContext(ctx,
+		`INSERT INTO alert_rules(chat_id,item_id,kind,level,window_minutes,cooldown_minutes,template,pin,enabled,created_at) VALUES(?,?,?,?,?,?,?,?,1,?)`,
+		r.ChatID, r.ItemID, r.Kind, r.Level, r.WindowMinutes, r.CooldownMinutes, r.Template, boolInt(r.Pin), time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err == nil {
+		d.record(ctx, r.ChatID, fmt.Sprintf("alert:%d", id), "", fmt.Sprintf("%s %s %v", r.ItemID, r.Kind, r.Level))
+	}
+	return id, err
+}
+
+// UpdateAlertRule sets one editable column of a rule.
+func (d *DB) UpdateAlertRule(ctx context.Context, ruleID int64, key string, value any) error {
+	allowed := map[string]bool{
+		"level": true, "window_minutes": true, "cooldown_minutes": true,
+		"template": true, "pin": true, "enabled": true,
+	}
+	if !allowed[key] {
+		return fmt.Errorf("invalid alert rule key: %s", key)
+	}
+	if bv, ok := value.(bool); ok {
+		value = boolInt(bv)
+	}
+	var chatID int64
+	var old sql.NullString
+	if auditing(ctx) {
+		_ = d.sql.QueryRowContext(ctx, fmt.Sprintf(`SELECT chat_id,%s FROM alert_rules WHERE rule_id=?`, key), ruleID).Scan(&chatID, &old)
+	}
+	_, err := d.sql.ExecContext(ctx, fmt.Sprintf(`UPDATE alert_rules SET %s=? WHERE rule_id=?`, key), value, ruleID)
+	if err == nil {
+		d.record(ctx, chatID, fmt.Sprintf("alert:%d.%s", ruleID, key), old.String, auditValue(value))
+	}
+	return err
+}
+
+func (d *DB) DeleteAlertRule(ctx context.Context, ruleID int64) error {
+	r, getErr := d.GetAlertRule(ctx, ruleID)
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM alert_rules WHERE rule_id=?`, ruleID)
+	if err == nil && getErr == nil {
+		d.record(ctx, r.ChatID, fmt.Sprintf("alert:%d", ruleID), fmt.Sprintf("%s %s %v", r.ItemID, r.Kind, r.Level), "")
+	}
+	return err
+}
+
+// SetAlertState records the value seen at the last evaluation and, if fired, when.
+func (d *DB) SetAlertState(ctx context.Context, ruleID int64, value float64, firedAt *time.Time) error {
+	if firedAt != nil {
+		_, err := d.sql.ExecContext(ctx, `UPDATE alert_rules SET last_value=?, last_fired_at=? WHERE rule_id=?`, value, firedAt.Unix(), ruleID)
+		return err
+	}
+	_, err := d.sql.ExecContext(ctx, `UPDATE alert_rules SET last_value=? WHERE rule_id=?`, value, ruleID)
+	return err
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
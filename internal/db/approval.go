@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Global setting keys of the approval policy.
+const (
+	ApprovalAutoAdminKey     = "approval_auto_admin"
+	ApprovalAllowlistKey     = "approval_allowlist"
+	ApprovalDenyStrangersKey = "approval_deny_strangers"
+	ApprovalExpiryHoursKey   = "approval_expiry_hours"
+)
+
+// ApprovalPolicy decides what happens when the bot is added to a new chat.
+// With everything off every chat waits for a manual approve/deny.
+type ApprovalPolicy struct {
+	// AutoApproveAdmins approves chats the bot is added to by a super or global admin.
+	AutoApproveAdmins bool
+	// Allowlist holds chat IDs and usernames (lower-case, without "@") that are approved automatically.
+	Allowlist []string
+	// DenyStrangers denies and leaves chats added by anyone who is not a bot admin
+	// (unless allowlisted).
+	DenyStrangers bool
+	// ExpiryHours denies and leaves chats still pending after that long (0 = never).
+	ExpiryHours int
+}
+
+func (d *DB) GetApprovalPolicy(ctx context.Context) (ApprovalPolicy, error) {
+	var p ApprovalPolicy
+	get := func(key string) (string, error) {
+		v, _, err := d.GetGlobalSetting(ctx, key)
+		return v, err
+	}
+	v, err := get(ApprovalAutoAdminKey)
+	if err != nil {
+		return p, err
+	}
+	p.AutoApproveAdmins = v == "1"
+	if v, err = get(ApprovalDenyStrangersKey); err != nil {
+		return p, err
+	}
+	p.DenyStrangers = v == "1"
+	if v, err = get(ApprovalExpiryHoursKey); err != nil {
+		return p, err
+	}
+	p.ExpiryHours, _ = strconv.Atoi(v)
+	if v, err = get(ApprovalAllowlistKey); err != nil {
+		return p, err
+	}
+	p.Allowlist, _ = ParseAllowlist(v)
+	return p, nil
+}
+
+// SetApprovalFlag stores one of the on/off approval settings
+// (ApprovalAutoAdminKey, ApprovalDenyStrangersKey).
+func (d *DB) SetApprovalFlag(ctx context.Context, key string, on bool) error {
+	return d.SetGlobalSetting(ctx, key, strconv.Itoa(boolInt(on)))
+}
+
+var allowUsernameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{3,31}$`)
+
+// ParseAllowlist splits text on commas and whitespace into normalized
+// allowlist entries: chat IDs ("-100123") and usernames ("mychannel").
+// Entries that are neither are returned as invalid.
+func ParseAllowlist(text string) (entries, invalid []string) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '،' || r == '\n' || r == ' ' || r == '\t'
+	})
+	seen := map[string]bool{}
+	for _, f := range fields {
+		e := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(f, "https://t.me/"), "@"))
+		if _, err := strconv.ParseInt(e, 10, 64); err != nil && !allowUsernameRe.MatchString(e) {
+			invalid = append(invalid, f)
+			continue
+		}
+		if !seen[e] {
+			seen[e] = true
+			entries = append(entries, e)
+		}
+	}
+	return entries, invalid
+}
+
+// Allowed reports whether a chat matches the allowlist by ID or username.
+func (p ApprovalPolicy) Allowed(chatID int64, username string) bool {
+	id := strconv.FormatInt(chatID, 10)
+	username = strings.ToLower(username)
+	for _, e := range p.Allowlist {
+		if e == id || (username != "" && e == username) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkChatRequested starts the pending-approval clock of chatID.
+func (d *DB) MarkChatRequested(ctx context.Context, chatID int64) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chats SET requested_at=? WHERE chat_id=?`, time.Now().Unix(), chatID)
+	return err
+}
+
+// ListPendingBefore returns chats still waiting for approval that were
+// requested before t.
+func (d *DB) ListPendingBefore(ctx context.Context, t time.Time) ([]Chat, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT chat_id,title,type FROM chats WHERE approved=0 AND requested_at IS NOT NULL AND requested_at<?`, t.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Chat
+	for rows.Next() {
+		var c Chat
+		if err := rows.Scan(&c.ChatID, &c.Title, &c.Type); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// AuditEntry is one configuration change. ChatID is 0 for global changes
+// (global settings, templates, admins).
+type AuditEntry struct {
+	ID       int64
+	At       time.Time
+	AdminID  int64
+	ChatID   int64
+	Key      string
+	OldValue string
+	NewValue string
+}
+
+type actorKey struct{}
+
+// WithActor marks ctx as acting on behalf of userID. Mutations made with
+// such a context are written to the audit log; background writes (the
+// scheduler) carry no actor and are not audited.
+func WithActor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+func actorFrom(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(actorKey{}).(int64)
+	return id, ok
+}
+
+// secretSettings are global settings whose values are not copied into the log.
+var secretSettings = map[string]bool{"bonbast_api_hash": true, "navasan_api_key": true}
+
+// record writes an audit row if ctx has an actor and the value changed.
+// Failures are ignored: auditing must never block the change itself.
+func (d *DB) record(ctx context.Context, chatID int64, key, oldValue, newValue string) {
+	userID, ok := actorFrom(ctx)
+	if !ok || oldValue == newValue {
+		return
+	}
+	_, _ = d.sql.ExecContext(ctx, `INSERT INTO audit_log(at,admin_id,chat_id,key,old_value,new_value) VALUES(?,?,?,?,?,?)`,
+		time.Now().Unix(), userID, chatID, key, oldValue, newValue)
+}
+
+// auditing reports whether ctx changes are audited, so callers can skip
+// reading old values otherwise.
+func auditing(ctx context.Context) bool {
+	_, ok := actorFrom(ctx)
+	return ok
+}
+
+// column reads one value as text for the audit log ("" if missing).
+func (d *DB) column(ctx context.Context, query string, args ...any) string {
+	var v sql.NullString
+	_ = d.sql.QueryRowContext(ctx, query, args...).Scan(&v)
+	return v.String
+}
+
+func auditValue(v any) string {
+	switch x := v.(type) {
+	case bool:
+		return strconv.Itoa(boolInt(x))
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// ListAudit returns the latest limit changes of chatID, newest first.
+func (d *DB) ListAudit(ctx context.Context, chatID int64, limit int) ([]AuditEntry, error) {
+	return d.queryAudit(ctx, `SELECT id,at,admin_id,chat_id,key,old_value,new_value FROM audit_log WHERE chat_id=? ORDER BY id DESC LIMIT ?`, chatID, limit)
+}
+
+// WriteAuditCSV writes the whole audit log, oldest first, as CSV.
+func (d *DB) WriteAuditCSV(ctx context.Context, w io.Writer) error {
+	entries, err := d.queryAudit(ctx, `SELECT id,at,admin_id,chat_id,key,old_value,new_value FROM audit_log ORDER BY id ASC`)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "time", "admin_id", "chat_id", "key", "old_value", "new_value"})
+	for _, e := range entries {
+		_ = cw.Write([]string{
+			strconv.FormatInt(e.ID, 10), e.At.UTC().Format(time.RFC3339),
+			strconv.FormatInt(e.AdminID, 10), strconv.FormatInt(e.ChatID, 10),
+			e.Key, e.OldValue, e.NewValue,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (d *DB) queryAudit(ctx context.Context, query string, args ...any) ([]AuditEntry, error) {
+	rows, err := d.sql.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var at int64
+		if err := rows.Scan(&e.ID, &at, &e.AdminID, &e.ChatID, &e.Key, &e.OldValue, &e.NewValue); err != nil {
+			return nil, err
+		}
+		e.At = time.Unix(at, 0)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,592 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// BundleVersion is the settings export format written by ExportChatSettings.
+// Version 1 (settings + items only) is still read.
+const BundleVersion = 2
+
+// Bundle is an exported chat configuration. Nil settings fields and nil
+// Template/Alerts/Digests mean "not in the file": the chat keeps its value.
+type Bundle struct {
+	Version  int            `json:"version"`
+	Settings BundleSettings `json:"settings"`
+	Items    []ChatItem     `json:"items"`
+	// Template carries the full template, so the file can be imported into
+	// another bot instance that does not have it.
+	Template *BundleTemplate `json:"template,omitempty"`
+	Alerts   []BundleAlert   `json:"alerts"`
+	Digests  []BundleDigest  `json:"digests"`
+}
+
+type BundleSettings struct {
+	SourceProvider           *string                      `json:"source_provider,omitempty"`
+	SourceMethod             *string                      `json:"source_method,omitempty"`
+	IntervalMinutes          *int                         `json:"interval_minutes,omitempty"`
+	DowntimeEnabled          *bool                        `json:"downtime_enabled,omitempty"`
+	DowntimeStart            *string                      `json:"downtime_start,omitempty"`
+	DowntimeEnd              *string                      `json:"downtime_end,omitempty"`
+	TriggerItems             *[]string                    `json:"trigger_items,omitempty"`
+	TriggerThresholdType     *string                      `json:"trigger_threshold_type,omitempty"`
+	TriggerThresholdValue    *float64                     `json:"trigger_threshold_value,omitempty"`
+	TriggerCooldownMinutes   *int                         `json:"trigger_cooldown_minutes,omitempty"`
+	TriggerMaxSilenceMinutes *int                         `json:"trigger_max_silence_minutes,omitempty"`
+	TriggerThresholds        *map[string]TriggerThreshold `json:"trigger_thresholds,omitempty"`
+	TriggerBaseline          *string                      `json:"trigger_baseline,omitempty"`
+	TriggerBaselineMinutes   *int                         `json:"trigger_baseline_minutes,omitempty"`
+	PostMode                 *string                      `json:"post_mode,omitempty"`
+	PriceMode                *string                      `json:"price_mode,omitempty"`
+	Digits                   *string                      `json:"digits,omitempty"`
+	ShowSameArrow            *bool                        `json:"show_same_arrow,omitempty"`
+	EditIgnoreTime           *bool                        `json:"edit_ignore_time,omitempty"`
+	CommandsEnabled          *bool                        `json:"commands_enabled,omitempty"`
+	CommandsCooldownSeconds  *int                         `json:"commands_cooldown_seconds,omitempty"`
+	TemplateID               *string                      `json:"template_id,omitempty"`
+}
+
+// BundleTemplate is the chat's template. MediaFileID is a Telegram file_id,
+// which only the bot that uploaded it can send.
+type BundleTemplate struct {
+	TemplateID  string `json:"template_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Body        string `json:"body"`
+	MediaType   string `json:"media_type"`
+	MediaFileID string `json:"media_file_id"`
+}
+
+type BundleAlert struct {
+	ItemID          string  `json:"item_id"`
+	Kind            string  `json:"kind"`
+	Level           float64 `json:"level"`
+	WindowMinutes   int     `json:"window_minutes"`
+	CooldownMinutes int     `json:"cooldown_minutes"`
+	Template        string  `json:"template"`
+	Pin             bool    `json:"pin"`
+	Enabled         bool    `json:"enabled"`
+}
+
+type BundleDigest struct {
+	Period   string `json:"period"`
+	Enabled  bool   `json:"enabled"`
+	AtTime   string `json:"at_time"`
+	Template string `json:"template"`
+}
+
+// settingKV is one chat setting as passed to UpdateChatSetting.
+type settingKV struct {
+	Key   string
+	Value any
+}
+
+// values lists the non-nil settings (except template_id) in a stable order.
+func (s BundleSettings) values() []settingKV {
+	var out []settingKV
+	add := func(key string, set bool, v func() any) {
+		if set {
+			out = append(out, settingKV{key, v()})
+		}
+	}
+	add("source_provider", s.SourceProvider != nil, func() any { return *s.SourceProvider })
+	add("source_method", s.SourceMethod != nil, func() any { return *s.SourceMethod })
+	add("interval_minutes", s.IntervalMinutes != nil, func() any { return *s.IntervalMinutes })
+	add("downtime_enabled", s.DowntimeEnabled != nil, func() any { return *s.DowntimeEnabled })
+	add("downtime_start", s.DowntimeStart != nil, func() any { return *s.DowntimeStart })
+	add("downtime_end", s.DowntimeEnd != nil, func() any { return *s.DowntimeEnd })
+	add("trigger_items", s.TriggerItems != nil, func() any { return *s.TriggerItems })
+	add("trigger_threshold_type", s.TriggerThresholdType != nil, func() any { return *s.TriggerThresholdType })
+	add("trigger_threshold_value", s.TriggerThresholdValue != nil, func() any { return *s.TriggerThresholdValue })
+	add("trigger_cooldown_minutes", s.TriggerCooldownMinutes != nil, func() any { return *s.TriggerCooldownMinutes })
+	add("trigger_max_silence_minutes", s.TriggerMaxSilenceMinutes != nil, func() any { return *s.TriggerMaxSilenceMinutes })
+	add("trigger_thresholds", s.TriggerThresholds != nil, func() any { return *s.TriggerThresholds })
+	add("trigger_baseline", s.TriggerBaseline != nil, func() any { return *s.TriggerBaseline })
+	add("trigger_baseline_minutes", s.TriggerBaselineMinutes != nil, func() any { return *s.TriggerBaselineMinutes })
+	add("post_mode", s.PostMode != nil, func() any { return *s.PostMode })
+	add("price_mode", s.PriceMode != nil, func() any { return *s.PriceMode })
+	add("digits", s.Digits != nil, func() any { return *s.Digits })
+	add("show_same_arrow", s.ShowSameArrow != nil, func() any { return *s.ShowSameArrow })
+	add("edit_ignore_time", s.EditIgnoreTime != nil, func() any { return *s.EditIgnoreTime })
+	add("commands_enabled", s.CommandsEnabled != nil, func() any { return *s.CommandsEnabled })
+	add("commands_cooldown_seconds", s.CommandsCooldownSeconds != nil, func() any { return *s.CommandsCooldownSeconds })
+	return out
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func bundleAlert(r AlertRule) BundleAlert {
+	return BundleAlert{
+		ItemID: r.ItemID, Kind: r.Kind, Level: r.Level, WindowMinutes: r.WindowMinutes,
+		CooldownMinutes: r.CooldownMinutes, Template: r.Template, Pin: r.Pin, Enabled: r.Enabled,
+	}
+}
+
+// ExportChatSettings exports chatID's configuration as a version 2 bundle.
+func (d *DB) ExportChatSettings(ctx context.Context, chatID int64) ([]byte, error) {
+	s, err := d.GetChatSettings(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	itemsList, err := d.ListChatItems(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	// Keep stable export order
+	sort.Slice(itemsList, func(i, j int) bool { return itemsList[i].Position < itemsList[j].Position })
+
+	b := Bundle{
+		Version: BundleVersion,
+		Settings: BundleSettings{
+			SourceProvider:           ptr(s.SourceProvider),
+			SourceMethod:             ptr(s.SourceMethod),
+			IntervalMinutes:          ptr(s.IntervalMinutes),
+			DowntimeEnabled:          ptr(s.DowntimeEnabled),
+			DowntimeStart:            ptr(s.DowntimeStart),
+			DowntimeEnd:              ptr(s.DowntimeEnd),
+			TriggerItems:             ptr(append([]string{}, s.TriggerItems...)),
+			TriggerThresholdType:     ptr(s.TriggerThresholdType),
+			TriggerThresholdValue:    ptr(s.TriggerThresholdValue),
+			TriggerCooldownMinutes:   ptr(s.TriggerCooldownMinutes),
+			TriggerMaxSilenceMinutes: ptr(s.TriggerMaxSilenceMinutes),
+			TriggerThresholds:        ptr(s.ItemThresholds),
+			TriggerBaseline:          ptr(s.TriggerBaseline),
+			TriggerBaselineMinutes:   ptr(s.TriggerBaselineMinutes),
+			PostMode:                 ptr(s.PostMode),
+			PriceMode:                ptr(s.PriceMode),
+			Digits:                   ptr(s.Digits),
+			ShowSameArrow:            ptr(s.ShowSameArrow),
+			EditIgnoreTime:           ptr(s.EditIgnoreTime),
+			CommandsEnabled:          ptr(s.CommandsEnabled),
+			CommandsCooldownSeconds:  ptr(s.CommandsCooldownSeconds),
+			TemplateID:               ptr(s.TemplateID),
+		},
+		Items:   itemsList,
+		Alerts:  []BundleAlert{},
+		Digests: []BundleDigest{},
+	}
+	if t, err := d.GetTemplate(ctx, s.TemplateID); err == nil {
+		b.Template = &BundleTemplate{
+			TemplateID: t.TemplateID, Name: t.Name, Description: t.Description,
+			Body: t.Body, MediaType: t.MediaType, MediaFileID: t.MediaFileID,
+		}
+	}
+	rules, err := d.ListAlertRules(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		b.Alerts = append(b.Alerts, bundleAlert(r))
+	}
+	for _, period := range DigestPeriods {
+		dg, err := d.GetDigest(ctx, chatID, period)
+		if err != nil {
+			return nil, err
+		}
+		b.Digests = append(b.Digests, BundleDigest{Period: period, Enabled: dg.Enabled, AtTime: dg.AtTime, Template: dg.Template})
+	}
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// ParseBundle decodes and validates a version 1 or 2 settings file. Version
+// 2 files are decoded strictly (unknown fields are an error).
+func ParseBundle(data []byte) (Bundle, error) {
+	var head struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return Bundle{}, fmt.Errorf("not a settings file: %w", err)
+	}
+	var b Bundle
+	switch head.Version {
+	case 1:
+		// v1 had only settings and items; older files may miss newer keys.
+		if err := json.Unmarshal(data, &b); err != nil {
+			return Bundle{}, err
+		}
+		b.Template, b.Alerts, b.Digests = nil, nil, nil
+	case BundleVersion:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&b); err != nil {
+			return Bundle{}, err
+		}
+	default:
+		return Bundle{}, fmt.Errorf("unsupported settings version: %d", head.Version)
+	}
+	if err := b.Validate(); err != nil {
+		return Bundle{}, err
+	}
+	return b, nil
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks every value in the bundle and reports all problems at once.
+func (b Bundle) Validate() error {
+	var problems []string
+	bad := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+	knownItem := func(field, id string) {
+		if _, ok := items.ByID(id); !ok {
+			bad("%s: unknown item %q", field, id)
+		}
+	}
+	hhmm := func(field string, v *string) {
+		if v != nil {
+			if _, ok := utils.ParseHHMM(*v); !ok {
+				bad("%s: %q is not HH:MM", field, *v)
+			}
+		}
+	}
+	nonNegative := func(field string, v *int) {
+		if v != nil && *v < 0 {
+			bad("%s: must not be negative", field)
+		}
+	}
+	enum := func(field string, v *string, allowed ...string) {
+		if v != nil && !oneOf(*v, allowed...) {
+			bad("%s: %q is not one of %s", field, *v, strings.Join(allowed, "/"))
+		}
+	}
+	threshold := func(field string, th TriggerThreshold) {
+		if !oneOf(th.Type, "abs", "pct") {
+			bad("%s: threshold type %q is not abs/pct", field, th.Type)
+		}
+		if th.Value < 0 {
+			bad("%s: threshold value must not be negative", field)
+		}
+	}
+
+	s := b.Settings
+	enum("source_provider", s.SourceProvider, "bonbast", "navasan")
+	enum("source_method", s.SourceMethod, "api", "scrape")
+	if s.IntervalMinutes != nil && (*s.IntervalMinutes < 1 || *s.IntervalMinutes > 120) {
+		bad("interval_minutes: %d is outside 1–120", *s.IntervalMinutes)
+	}
+	hhmm("downtime_start", s.DowntimeStart)
+	hhmm("downtime_end", s.DowntimeEnd)
+	if s.TriggerItems != nil {
+		for _, id := range *s.TriggerItems {
+			knownItem("trigger_items", id)
+		}
+	}
+	enum("trigger_threshold_type", s.TriggerThresholdType, "abs", "pct")
+	if s.TriggerThresholdValue != nil && *s.TriggerThresholdValue < 0 {
+		bad("trigger_threshold_value: must not be negative")
+	}
+	nonNegative("trigger_cooldown_minutes", s.TriggerCooldownMinutes)
+	nonNegative("trigger_max_silence_minutes", s.TriggerMaxSilenceMinutes)
+	nonNegative("trigger_baseline_minutes", s.TriggerBaselineMinutes)
+	nonNegative("commands_cooldown_seconds", s.CommandsCooldownSeconds)
+	if s.TriggerThresholds != nil {
+		for id, th := range *s.TriggerThresholds {
+			knownItem("trigger_thresholds", id)
+			threshold("trigger_thresholds."+id, th)
+		}
+	}
+	enum("trigger_baseline", s.TriggerBaseline, "last_post", "day_open", "minutes_ago")
+	enum("post_mode", s.PostMode, "new", "edit", "repost", "pin")
+	enum("price_mode", s.PriceMode, "sell", "buy", "both")
+	enum("digits", s.Digits, "en", "fa")
+	if s.TemplateID != nil && *s.TemplateID == "" {
+		bad("template_id: empty")
+	}
+
+	seen := map[string]bool{}
+	for _, it := range b.Items {
+		knownItem("items", it.ItemID)
+		if seen[it.ItemID] {
+			bad("items: %q listed twice", it.ItemID)
+		}
+		seen[it.ItemID] = true
+	}
+
+	if t := b.Template; t != nil {
+		if t.TemplateID == "" {
+			bad("template.template_id: empty")
+		}
+		if s.TemplateID != nil && *s.TemplateID != t.TemplateID {
+			bad("template.template_id: %q does not match settings.template_id %q", t.TemplateID, *s.TemplateID)
+		}
+		if strings.TrimSpace(t.Name) == "" {
+			bad("template.name: empty")
+		}
+		if strings.TrimSpace(t.Body) == "" {
+			bad("template.body: empty")
+		}
+		if t.MediaType == "" && t.MediaFileID != "" {
+			bad("template: media_file_id without media_type")
+		}
+		if t.MediaType != "" {
+			tm := Template{MediaType: t.MediaType, MediaFileID: t.MediaFileID}
+			if err := ValidateMedia(t.MediaType, tm.Media()); err != nil {
+				bad("template media: %v", err)
+			}
+		}
+	}
+
+	for i, r := range b.Alerts {
+		field := fmt.Sprintf("alerts[%d]", i)
+		knownItem(field, r.ItemID)
+		if !oneOf(r.Kind, "above", "below", "move") {
+			bad("%s: kind %q is not above/below/move", field, r.Kind)
+		}
+		if r.Level <= 0 {
+			bad("%s: level must be positive", field)
+		}
+		if r.Kind == "move" && r.WindowMinutes <= 0 {
+			bad("%s: window_minutes must be positive for move alerts", field)
+		}
+		if r.CooldownMinutes < 0 {
+			bad("%s: cooldown_minutes must not be negative", field)
+		}
+	}
+
+	periods := map[string]bool{}
+	for i, dg := range b.Digests {
+		field := fmt.Sprintf("digests[%d]", i)
+		if !oneOf(dg.Period, DigestPeriods...) {
+			bad("%s: period %q is not %s", field, dg.Period, strings.Join(DigestPeriods, "/"))
+		}
+		if periods[dg.Period] {
+			bad("%s: period %q listed twice", field, dg.Period)
+		}
+		periods[dg.Period] = true
+		hhmm(field+".at_time", &dg.AtTime)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid settings file:\n- %s", strings.Join(problems, "\n- "))
+	}
+	return nil
+}
+
+// ImportReport is the dry-run result of importing a bundle into a chat.
+type ImportReport struct {
+	Version int
+	Changes []SettingDiff
+	// NewTemplate is set when the bundle's template does not exist here and
+	// will be created.
+	NewTemplate bool
+	// MediaFileID is set when the template carries media; the file_id only
+	// works if the file was uploaded through this same bot.
+	MediaFileID bool
+	// SkippedTemplate is the template_id of a v1 file that does not exist
+	// here; the chat keeps its current template.
+	SkippedTemplate string
+}
+
+// resolveTemplate returns the template the bundle selects and whether it must
+// be created from b.Template. A template_id that exists here is reused as is.
+// A v1 file (which never carries the template) naming a template that does
+// not exist here keeps the chat's template; skipped is that template_id.
+func (d *DB) resolveTemplate(ctx context.Context, b Bundle) (tid string, create bool, skipped string, err error) {
+	if b.Settings.TemplateID != nil {
+		tid = *b.Settings.TemplateID
+	} else if b.Template != nil {
+		tid = b.Template.TemplateID
+	}
+	if tid == "" {
+		return "", false, "", nil
+	}
+	_, err = d.GetTemplate(ctx, tid)
+	if err == nil {
+		return tid, false, "", nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", false, "", err
+	}
+	if b.Template != nil {
+		return tid, true, "", nil
+	}
+	if b.Version < BundleVersion {
+		return "", false, tid, nil
+	}
+	return "", false, "", fmt.Errorf("template %q does not exist and the file does not include it", tid)
+}
+
+// DryRunImport reports what ImportBundle(chatID, b) would change without
+// changing anything.
+func (d *DB) DryRunImport(ctx context.Context, chatID int64, b Bundle) (ImportReport, error) {
+	rep := ImportReport{Version: b.Version}
+	cur, err := d.GetChatSettings(ctx, chatID)
+	if err != nil {
+		return rep, err
+	}
+	for _, kv := range b.Settings.values() {
+		from, to := formatSetting(settingValue(cur, kv.Key)), formatSetting(kv.Value)
+		if from != to {
+			rep.Changes = append(rep.Changes, SettingDiff{Section: "settings", Key: kv.Key, From: from, To: to})
+		}
+	}
+
+	tid, create, skipped, err := d.resolveTemplate(ctx, b)
+	if err != nil {
+		return rep, err
+	}
+	rep.SkippedTemplate = skipped
+	if create {
+		rep.NewTemplate = true
+		rep.Changes = append(rep.Changes, SettingDiff{Section: "template", Key: "template_id", From: cur.TemplateID, To: "new: " + b.Template.Name})
+	} else if tid != "" && tid != cur.TemplateID {
+		rep.Changes = append(rep.Changes, SettingDiff{Section: "template", Key: "template_id", From: cur.TemplateID, To: tid})
+	}
+	rep.MediaFileID = create && b.Template.MediaFileID != ""
+
+	if len(b.Items) > 0 {
+		curItems, err := d.ListChatItems(ctx, chatID)
+		if err != nil {
+			return rep, err
+		}
+		from, to := enabledItemOrder(curItems), enabledItemOrder(append([]ChatItem{}, b.Items...))
+		if from != to {
+			rep.Changes = append(rep.Changes, SettingDiff{Section: "items", Key: "items", From: from, To: to})
+		}
+	}
+
+	if b.Alerts != nil {
+		rules, err := d.ListAlertRules(ctx, chatID)
+		if err != nil {
+			return rep, err
+		}
+		cur := make([]BundleAlert, 0, len(rules))
+		for _, r := range rules {
+			cur = append(cur, bundleAlert(r))
+		}
+		if fmt.Sprint(cur) != fmt.Sprint(b.Alerts) {
+			rep.Changes = append(rep.Changes, SettingDiff{Section: "alerts", Key: "alerts",
+				From: fmt.Sprintf("%d rules", len(rules)), To: fmt.Sprintf("%d rules", len(b.Alerts))})
+		}
+	}
+	for _, bd := range b.Digests {
+		dg, err := d.GetDigest(ctx, chatID, bd.Period)
+		if err != nil {
+			return rep, err
+		}
+		from := fmt.Sprintf("%s %s %q", auditValue(dg.Enabled), dg.AtTime, dg.Template)
+		to := fmt.Sprintf("%s %s %q", auditValue(bd.Enabled), bd.AtTime, bd.Template)
+		if from != to {
+			rep.Changes = append(rep.Changes, SettingDiff{Section: "digests", Key: "digest:" + bd.Period, From: from, To: to})
+		}
+	}
+	return rep, nil
+}
+
+// ImportBundle applies a validated bundle to chatID in one transaction: on
+// any failure nothing is changed.
+func (d *DB) ImportBundle(ctx context.Context, chatID int64, b Bundle) error {
+	return d.inTx(ctx, func(tx *DB) error { return tx.applyBundle(ctx, chatID, b) })
+}
+
+func (d *DB) applyBundle(ctx context.Context, chatID int64, b Bundle) error {
+	tid, create, _, err := d.resolveTemplate(ctx, b)
+	if err != nil {
+		return err
+	}
+	if create {
+		owner, _ := actorFrom(ctx)
+		t, err := d.CreateTemplate(ctx, b.Template.Name, b.Template.Description, b.Template.Body, owner)
+		if err != nil {
+			return fmt.Errorf("create template: %w", err)
+		}
+		if b.Template.MediaType != "" {
+			if err := d.SetTemplateMedia(ctx, t.TemplateID, b.Template.MediaType, b.Template.MediaFileID); err != nil {
+				return fmt.Errorf("template media: %w", err)
+			}
+		}
+		tid = t.TemplateID
+	}
+	if tid != "" {
+		if err := d.UpdateChatSetting(ctx, chatID, "template_id", tid); err != nil {
+			return fmt.Errorf("template_id: %w", err)
+		}
+	}
+
+	for _, kv := range b.Settings.values() {
+		if kv.Key == "trigger_thresholds" {
+			if err := d.cloneThresholds(ctx, kv.Value.(map[string]TriggerThreshold), chatID); err != nil {
+				return fmt.Errorf("trigger_thresholds: %w", err)
+			}
+			continue
+		}
+		if err := d.UpdateChatSetting(ctx, chatID, kv.Key, kv.Value); err != nil {
+			return fmt.Errorf("%s: %w", kv.Key, err)
+		}
+	}
+
+	for _, it := range b.Items {
+		if _, err := d.sql.ExecContext(ctx, `INSERT INTO chat_items(chat_id,item_id,position,enabled) VALUES(?,?,?,?)
+			ON CONFLICT(chat_id,item_id) DO UPDATE SET position=excluded.position, enabled=excluded.enabled`,
+			chatID, it.ItemID, it.Position, boolInt(it.Enabled)); err != nil {
+			return fmt.Errorf("items: %w", err)
+		}
+	}
+	if len(b.Items) > 0 {
+		d.record(ctx, chatID, "import:items", "", fmt.Sprint(len(b.Items)))
+		// normalize positions to avoid duplicates
+		if err := d.normalizePositions(ctx, chatID); err != nil {
+			return err
+		}
+	}
+
+	if b.Alerts != nil {
+		rules, err := d.ListAlertRules(ctx, chatID)
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			if err := d.DeleteAlertRule(ctx, r.RuleID); err != nil {
+				return fmt.Errorf("alerts: %w", err)
+			}
+		}
+		for _, ba := range b.Alerts {
+			id, err := d.CreateAlertRule(ctx, AlertRule{
+				ChatID: chatID, ItemID: ba.ItemID, Kind: ba.Kind, Level: ba.Level, WindowMinutes: ba.WindowMinutes,
+				CooldownMinutes: ba.CooldownMinutes, Template: ba.Template, Pin: ba.Pin,
+			})
+			if err != nil {
+				return fmt.Errorf("alerts: %w", err)
+			}
+			if !ba.Enabled {
+				if err := d.UpdateAlertRule(ctx, id, "enabled", false); err != nil {
+					return fmt.Errorf("alerts: %w", err)
+				}
+			}
+		}
+	}
+
+	for _, bd := range b.Digests {
+		for _, kv := range []settingKV{{"enabled", bd.Enabled}, {"at_time", bd.AtTime}, {"template", bd.Template}} {
+			if err := d.UpdateDigest(ctx, chatID, bd.Period, kv.Key, kv.Value); err != nil {
+				return fmt.Errorf("digest %s: %w", bd.Period, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportChatSettings parses, validates and applies a version 1 or 2 settings file.
+func (d *DB) ImportChatSettings(ctx context.Context, chatID int64, data []byte) error {
+	b, err := ParseBundle(data)
+	if err != nil {
+		return err
+	}
+	return d.ImportBundle(ctx, chatID, b)
+}
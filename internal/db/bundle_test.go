@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseBundle(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr []string // substrings of the error; nil means no error
+	}{
+		{"v1 baseline export", `{"version":1,"settings":{"source_provider":"bonbast","interval_minutes":10,"template_id":"default"},"items":[{"ItemID":"USD","Position":0,"Enabled":true}],"legacy":true}`, nil},
+		{"v2 minimal", `{"version":2,"settings":{},"items":null,"alerts":null,"digests":null}`, nil},
+		{"v2 unknown field", `{"version":2,"settings":{"colour":"red"}}`, []string{`unknown field "colour"`}},
+		{"unsupported version", `{"version":3}`, []string{"unsupported settings version: 3"}},
+		{"not json", `hello`, []string{"not a settings file"}},
+		{"invalid enums", `{"version":2,"settings":{"source_provider":"xe","post_mode":"shout","trigger_baseline":"week"}}`, []string{
+			`source_provider: "xe" is not one of bonbast/navasan`,
+			`post_mode: "shout"`,
+			`trigger_baseline: "week"`,
+		}},
+		{"invalid items", `{"version":2,"settings":{"trigger_items":["XYZ"]},"items":[{"ItemID":"USD"},{"ItemID":"USD"},{"ItemID":"NOPE"}]}`, []string{
+			`trigger_items: unknown item "XYZ"`,
+			`items: "USD" listed twice`,
+			`items: unknown item "NOPE"`,
+		}},
+		{"invalid HH:MM", `{"version":2,"settings":{"downtime_start":"25:00","downtime_end":"7am"},"digests":[{"period":"daily","at_time":"9:6x"}]}`, []string{
+			`downtime_start: "25:00" is not HH:MM`,
+			`downtime_end: "7am" is not HH:MM`,
+			`digests[0].at_time: "9:6x" is not HH:MM`,
+		}},
+		{"v1 invalid enum", `{"version":1,"settings":{"digits":"roman"}}`, []string{`digits: "roman"`}},
+	}
+	for _, tt := range tests {
+		_, err := ParseBundle([]byte(tt.data))
+		if tt.wantErr == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		for _, want := range tt.wantErr {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not mention %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	d, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	const chatID = -100
+	if err := d.UpsertChat(ctx, chatID, "test", "supergroup"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := d.ExportChatSettings(ctx, chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseBundle(data)
+	if err != nil {
+		t.Fatalf("exported file does not parse: %v", err)
+	}
+	if b.Version != BundleVersion {
+		t.Errorf("version = %d, want %d", b.Version, BundleVersion)
+	}
+	rep, err := d.DryRunImport(ctx, chatID, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Changes) != 0 || rep.NewTemplate {
+		t.Errorf("re-importing the export changes %+v", rep)
+	}
+	if err := d.ImportBundle(ctx, chatID, b); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	ctx := context.Background()
+	d, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	missing := "no-such-template"
+	tests := []struct {
+		name        string
+		b           Bundle
+		wantTID     string
+		wantCreate  bool
+		wantSkipped string
+		wantErr     bool
+	}{
+		{"none", Bundle{Version: 2}, "", false, "", false},
+		{"v1 missing is skipped", Bundle{Version: 1, Settings: BundleSettings{TemplateID: &missing}}, "", false, missing, false},
+		{"v2 missing fails", Bundle{Version: 2, Settings: BundleSettings{TemplateID: &missing}}, "", false, "", true},
+		{"v2 missing with template is created", Bundle{Version: 2, Settings: BundleSettings{TemplateID: &missing},
+			Template: &BundleTemplate{TemplateID: missing, Name: "x", Body: "x"}}, missing, true, "", false},
+	}
+	for _, tt := range tests {
+		tid, create, skipped, err := d.resolveTemplate(ctx, tt.b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tid != tt.wantTID || create != tt.wantCreate || skipped != tt.wantSkipped {
+			t.Errorf("%s: got (%q, %v, %q), want (%q, %v, %q)", tt.name, tid, create, skipped, tt.wantTID, tt.wantCreate, tt.wantSkipped)
+		}
+	}
+}
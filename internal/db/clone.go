@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CloneSections lists the parts of a chat's settings that can be copied to
+// another chat, in menu order.
+var CloneSections = []string{"items", "schedule", "template", "triggers"}
+
+// cloneSectionKeys are the chat_settings keys of each section. "items" has
+// no keys; it copies chat_items (enabled items and their order).
+var cloneSectionKeys = map[string][]string{
+	"schedule": {"interval_minutes", "downtime_enabled", "downtime_start", "downtime_end"},
+	"template": {"template_id", "digits", "price_mode", "show_same_arrow"},
+	"triggers": {
+		"trigger_items", "trigger_threshold_type", "trigger_threshold_value",
+		"trigger_cooldown_minutes", "trigger_max_silence_minutes",
+		"trigger_baseline", "trigger_baseline_minutes", "trigger_thresholds",
+	},
+}
+
+// SettingDiff is one value that a clone would change in the target chat.
+type SettingDiff struct {
+	Section string
+	Key     string
+	From    string
+	To      string
+}
+
+// settingValue returns the value of key in s as accepted by UpdateChatSetting.
+func settingValue(s ChatSettings, key string) any {
+	switch key {
+	case "source_provider":
+		return s.SourceProvider
+	case "source_method":
+		return s.SourceMethod
+	case "post_mode":
+		return s.PostMode
+	case "edit_ignore_time":
+		return s.EditIgnoreTime
+	case "commands_enabled":
+		return s.CommandsEnabled
+	case "commands_cooldown_seconds":
+		return s.CommandsCooldownSeconds
+	case "interval_minutes":
+		return s.IntervalMinutes
+	case "downtime_enabled":
+		return s.DowntimeEnabled
+	case "downtime_start":
+		return s.DowntimeStart
+	case "downtime_end":
+		return s.DowntimeEnd
+	case "template_id":
+		return s.TemplateID
+	case "digits":
+		return s.Digits
+	case "price_mode":
+		return s.PriceMode
+	case "show_same_arrow":
+		return s.ShowSameArrow
+	case "trigger_items":
+		return s.TriggerItems
+	case "trigger_threshold_type":
+		return s.TriggerThresholdType
+	case "trigger_threshold_value":
+		return s.TriggerThresholdValue
+	case "trigger_cooldown_minutes":
+		return s.TriggerCooldownMinutes
+	case "trigger_max_silence_minutes":
+		return s.TriggerMaxSilenceMinutes
+	case "trigger_baseline":
+		return s.TriggerBaseline
+	case "trigger_baseline_minutes":
+		return s.TriggerBaselineMinutes
+	case "trigger_thresholds":
+		return s.ItemThresholds
+	}
+	return nil
+}
+
+func formatSetting(v any) string {
+	switch x := v.(type) {
+	case []string:
+		return strings.Join(x, ", ")
+	case map[string]TriggerThreshold:
+		ids := make([]string, 0, len(x))
+		for id := range x {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		parts := make([]string, 0, len(ids))
+		for _, id := range ids {
+			parts = append(parts, fmt.Sprintf("%s:%s %v", id, x[id].Type, x[id].Value))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return auditValue(v)
+}
+
+func enabledItemOrder(list []ChatItem) string {
+	sort.Slice(list, func(i, j int) bool { return list[i].Position < list[j].Position })
+	ids := []string{}
+	for _, it := range list {
+		if it.Enabled {
+			ids = append(ids, it.ItemID)
+		}
+	}
+	return strings.Join(ids, ", ")
+}
+
+// DiffChatSettings lists what CloneChatSettings(srcID, dstID, sections)
+// would change in dstID.
+func (d *DB) DiffChatSettings(ctx context.Context, srcID, dstID int64, sections []string) ([]SettingDiff, error) {
+	src, err := d.GetChatSettings(ctx, srcID)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := d.GetChatSettings(ctx, dstID)
+	if err != nil {
+		return nil, err
+	}
+	var out []SettingDiff
+	for _, sec := range sections {
+		if sec == "items" {
+			srcItems, err := d.ListChatItems(ctx, srcID)
+			if err != nil {
+				return nil, err
+			}
+			dstItems, err := d.ListChatItems(ctx, dstID)
+			if err != nil {
+				return nil, err
+			}
+			from, to := enabledItemOrder(dstItems), enabledItemOrder(srcItems)
+			if from != to {
+				out = append(out, SettingDiff{Section: sec, Key: "items", From: from, To: to})
+			}
+			continue
+		}
+		for _, key := range cloneSectionKeys[sec] {
+			from, to := formatSetting(settingValue(dst, key)), formatSetting(settingValue(src, key))
+			if from != to {
+				out = append(out, SettingDiff{Section: sec, Key: key, From: from, To: to})
+			}
+		}
+	}
+	return out, nil
+}
+
+// CloneChatSettings copies the given sections of srcID's settings into dstID
+// in one transaction, so a failing section leaves dstID untouched.
+func (d *DB) CloneChatSettings(ctx context.Context, srcID, dstID int64, sections []string) error {
+	return d.inTx(ctx, func(tx *DB) error {
+		src, err := tx.GetChatSettings(ctx, srcID)
+		if err != nil {
+			return err
+		}
+		for _, sec := range sections {
+			if sec == "items" {
+				if err := tx.cloneItems(ctx, srcID, dstID); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, key := range cloneSectionKeys[sec] {
+				if key == "trigger_thresholds" {
+					if err := tx.cloneThresholds(ctx, src.ItemThresholds, dstID); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := tx.UpdateChatSetting(ctx, dstID, key, settingValue(src, key)); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// cloneItems gives dstID the enabled items and order of srcID. Items srcID
+// does not have are disabled and moved to the end.
+func (d *DB) cloneItems(ctx context.Context, srcID, dstID int64) error {
+	srcItems, err := d.ListChatItems(ctx, srcID)
+	if err != nil {
+		return err
+	}
+	dstItems, err := d.ListChatItems(ctx, dstID)
+	if err != nil {
+		return err
+	}
+	old := enabledItemOrder(dstItems)
+	inSrc := map[string]bool{}
+	for _, it := range srcItems {
+		inSrc[it.ItemID] = true
+		if _, err := d.sql.ExecContext(ctx, `INSERT INTO chat_items(chat_id,item_id,position,enabled) VALUES(?,?,?,?)
+			ON CONFLICT(chat_id,item_id) DO UPDATE SET position=excluded.position, enabled=excluded.enabled`,
+			dstID, it.ItemID, it.Position, boolInt(it.Enabled)); err != nil {
+			return err
+		}
+	}
+	for _, it := range dstItems {
+		if inSrc[it.ItemID] {
+			continue
+		}
+		if _, err := d.sql.ExecContext(ctx, `UPDATE chat_items SET position=?, enabled=0 WHERE chat_id=? AND item_id=?`,
+			len(srcItems)+it.Position, dstID, it.ItemID); err != nil {
+			return err
+		}
+	}
+	if err := d.normalizePositions(ctx, dstID); err != nil {
+		return err
+	}
+	d.record(ctx, dstID, "items", old, enabledItemOrder(srcItems))
+	return nil
+}
+
+// cloneThresholds makes dstID's per-item threshold overrides equal to ths.
+func (d *DB) cloneThresholds(ctx context.Context, ths map[string]TriggerThreshold, dstID int64) error {
+	current, err := d.getItemThresholds(ctx, dstID)
+	if err != nil {
+		return err
+	}
+	for id := range current {
+		if _, ok := ths[id]; !ok {
+			if err := d.ClearItemThreshold(ctx, dstID, id); err != nil {
+				return err
+			}
+		}
+	}
+	for id, th := range ths {
+		if current[id] == th {
+			continue
+		}
+		if err := d.SetItemThreshold(ctx, dstID, id, th); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,14 +21,42 @@ import (
 )
 
 type DB struct {
-	sql *sql.DB
+	// sql runs the queries: the pool, or a transaction inside inTx.
+	sql conn
+	// pool is the underlying database handle.
+	pool *sql.DB
+}
+
+// conn is implemented by both *sql.DB and *sql.Tx.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// inTx runs fn with a DB whose queries all go through one transaction, and
+// commits only if fn succeeds. Methods that open their own transaction must
+// not be called from fn.
+func (d *DB) inTx(ctx context.Context, fn func(tx *DB) error) error {
+	if _, nested := d.sql.(*sql.Tx); nested {
+		return fn(d)
+	}
+	tx, err := d.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := fn(&DB{sql: tx, pool: d.pool}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func Open(dbPath string) (*DB, error) {
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0o750); err != nil {
 		return nil, err
 	}
-	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)", dbPath)
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)", dbPath)
 	sqldb, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
@@ -36,7 +65,7 @@ func Open(dbPath string) (*DB, error) {
 	sqldb.SetMaxOpenConns(1) // SQLite best practice for embedded use
 	sqldb.SetConnMaxLifetime(0)
 
-	db := &DB{sql: sqldb}
+	db := &DB{sql: sqldb, pool: sqldb}
 	if err := db.migrate(context.Background()); err != nil {
 		_ = sqldb.Close()
 		return nil, err
@@ -49,7 +78,7 @@ func Open(dbPath string) (*DB, error) {
 }
 
 func (d *DB) Close() error {
-	return d.sql.Close()
+	return d.pool.Close()
 }
 
 func (d *DB) migrate(ctx context.Context) error {
@@ -76,15 +105,25 @@ func (d *DB) migrate(ctx context.Context) error {
 			trigger_items TEXT NOT NULL DEFAULT '[]',
 			trigger_threshold_type TEXT NOT NULL DEFAULT 'abs',
 			trigger_threshold_value REAL NOT NULL DEFAULT 0,
+			trigger_cooldown_minutes INTEGER NOT NULL DEFAULT 0,
+			trigger_max_silence_minutes INTEGER NOT NULL DEFAULT 0,
+			trigger_baseline TEXT NOT NULL DEFAULT 'last_post',
+			trigger_baseline_minutes INTEGER NOT NULL DEFAULT 60,
 			post_mode TEXT NOT NULL DEFAULT 'edit',
 			price_mode TEXT NOT NULL DEFAULT 'sell',
 			digits TEXT NOT NULL DEFAULT 'en',
 			show_same_arrow INTEGER NOT NULL DEFAULT 0,
+			edit_ignore_time INTEGER NOT NULL DEFAULT 0,
+			commands_enabled INTEGER NOT NULL DEFAULT 0,
+			commands_cooldown_seconds INTEGER NOT NULL DEFAULT 30,
 			template_id TEXT NOT NULL DEFAULT 'tmpl_default',
 			last_post_message_id INTEGER,
+			last_post_media TEXT,
+			last_post_hash TEXT,
 			last_post_time INTEGER,
 			last_fetch_time INTEGER,
-			last_error TEXT
+			last_error TEXT,
+			next_due_at INTEGER
 		);`,
 		`CREATE TABLE IF NOT EXISTS chat_items (
 			chat_id INTEGER NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
@@ -115,6 +154,122 @@ func (d *DB) migrate(ctx context.Context) error {
 			key TEXT PRIMARY KEY,
 			value TEXT NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS chat_trigger_thresholds (
+			chat_id INTEGER NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
+			item_id TEXT NOT NULL,
+			threshold_type TEXT NOT NULL DEFAULT 'abs',
+			threshold_value REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY(chat_id, item_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS price_history (
+			provider TEXT NOT NULL,
+			method TEXT NOT NULL,
+			item_id TEXT NOT NULL,
+			at INTEGER NOT NULL,
+			sell REAL,
+			buy REAL,
+			PRIMARY KEY(provider, method, item_id, at)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_price_history_at ON price_history(at);`,
+		`CREATE TABLE IF NOT EXISTS alert_rules (
+			rule_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
+			item_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			level REAL NOT NULL DEFAULT 0,
+			window_minutes INTEGER NOT NULL DEFAULT 60,
+			cooldown_minutes INTEGER NOT NULL DEFAULT 60,
+			template TEXT NOT NULL DEFAULT '',
+			pin INTEGER NOT NULL DEFAULT 0,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			last_value REAL,
+			last_fired_at INTEGER,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS chat_messages (
+			chat_id INTEGER NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
+			message_id INTEGER NOT NULL,
+			kind TEXT NOT NULL DEFAULT 'board',
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY(chat_id, message_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS chat_digests (
+			chat_id INTEGER NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
+			period TEXT NOT NULL,
+			enabled INTEGER NOT NULL DEFAULT 0,
+			at_time TEXT NOT NULL DEFAULT '23:00',
+			template TEXT NOT NULL DEFAULT '',
+			last_sent_key TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY(chat_id, period)
+		);`,
+		`CREATE TABLE IF NOT EXISTS chat_owner_policy (
+			chat_id INTEGER PRIMARY KEY REFERENCES chats(chat_id) ON DELETE CASCADE,
+			enabled INTEGER NOT NULL DEFAULT 0,
+			min_interval INTEGER NOT NULL DEFAULT 5,
+			max_interval INTEGER NOT NULL DEFAULT 120
+		);`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			at INTEGER NOT NULL,
+			admin_id INTEGER NOT NULL,
+			chat_id INTEGER NOT NULL DEFAULT 0,
+			key TEXT NOT NULL,
+			old_value TEXT NOT NULL DEFAULT '',
+			new_value TEXT NOT NULL DEFAULT ''
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_chat ON audit_log(chat_id, id);`,
+		`CREATE TABLE IF NOT EXISTS settings_profiles (
+			profile_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			data TEXT NOT NULL,
+			created_by INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS chat_profiles (
+			chat_id INTEGER PRIMARY KEY REFERENCES chats(chat_id) ON DELETE CASCADE,
+			profile_id INTEGER NOT NULL REFERENCES settings_profiles(profile_id) ON DELETE CASCADE,
+			linked INTEGER NOT NULL DEFAULT 0
+		);`,
+		`CREATE TABLE IF NOT EXISTS template_revisions (
+			rev_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			template_id TEXT NOT NULL REFERENCES templates(template_id) ON DELETE CASCADE,
+			body TEXT NOT NULL,
+			media_type TEXT NOT NULL DEFAULT '',
+			media_file_id TEXT NOT NULL DEFAULT '',
+			created_by INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_template_revisions_tmpl ON template_revisions(template_id, rev_id);`,
+		`CREATE TABLE IF NOT EXISTS admin_chats (
+			user_id INTEGER NOT NULL REFERENCES admins(user_id) ON DELETE CASCADE,
+			chat_id INTEGER NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
+			PRIMARY KEY(user_id, chat_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS user_subscriptions (
+			user_id INTEGER PRIMARY KEY,
+			enabled INTEGER NOT NULL DEFAULT 0,
+			at_time TEXT NOT NULL DEFAULT '09:00',
+			items TEXT NOT NULL DEFAULT '[]',
+			last_sent_key TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS user_alerts (
+			alert_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			item_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			level REAL NOT NULL,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_alerts_user ON user_alerts(user_id);`,
+		`CREATE TABLE IF NOT EXISTS leases (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			expires_at INTEGER NOT NULL,
+			heartbeat_at INTEGER NOT NULL
+		);`,
 		`CREATE INDEX IF NOT EXISTS idx_chat_items_chat_position ON chat_items(chat_id, position);`,
 	}
 	for _, s := range stmts {
@@ -122,9 +277,76 @@ func (d *DB) migrate(ctx context.Context) error {
 			return err
 		}
 	}
+
+	// Columns added after the first release. CREATE TABLE above already has
+	// them for fresh databases; older databases get them via ALTER TABLE.
+	columns := []struct{ table, column, def string }{
+		{"chat_settings", "next_due_at", "INTEGER"},
+		{"chat_settings", "trigger_cooldown_minutes", "INTEGER NOT NULL DEFAULT 0"},
+		{"chat_settings", "trigger_max_silence_minutes", "INTEGER NOT NULL DEFAULT 0"},
+		{"chat_settings", "trigger_baseline", "TEXT NOT NULL DEFAULT 'last_post'"},
+		{"chat_settings", "trigger_baseline_minutes", "INTEGER NOT NULL DEFAULT 60"},
+		{"chat_settings", "last_post_media", "TEXT"},
+		{"chat_settings", "last_post_hash", "TEXT"},
+		{"chat_settings", "edit_ignore_time", "INTEGER NOT NULL DEFAULT 0"},
+		{"chat_settings", "commands_enabled", "INTEGER NOT NULL DEFAULT 0"},
+		{"chat_settings", "commands_cooldown_seconds", "INTEGER NOT NULL DEFAULT 30"},
+		{"admins", "role", "TEXT NOT NULL DEFAULT 'admin'"},
+		{"chats", "problem", "TEXT NOT NULL DEFAULT ''"},
+		{"chats", "problem_at", "INTEGER"},
+		{"chats", "requested_at", "INTEGER"},
+		{"chat_settings", "media_index", "INTEGER NOT NULL DEFAULT 0"},
+		{"chat_settings", "last_trigger_time", "INTEGER"},
+		{"chat_messages", "group_id", "INTEGER"},
+	}
+	for _, c := range columns {
+		if err := d.addColumn(ctx, c.table, c.column, c.def); err != nil {
+			return err
+		}
+	}
+	// Chats pending from before requested_at existed start their approval
+	// clock now, so the expiry policy covers them too.
+	if _, err := d.sql.ExecContext(ctx, `UPDATE chats SET requested_at=? WHERE approved=0 AND enabled=1 AND requested_at IS NULL`, time.Now().Unix()); err != nil {
+		return err
+	}
+	// Admins from before roles existed: is_super maps to the super role.
+	if _, err := d.sql.ExecContext(ctx, `UPDATE admins SET role='super' WHERE is_super=1 AND role<>'super'`); err != nil {
+		return err
+	}
 	return nil
 }
 
+// addColumn adds table.column if it doesn't exist yet.
+func (d *DB) addColumn(ctx context.Context, table, column, def string) error {
+	rows, err := d.sql.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_ = rows.Close()
+	_, err = d.sql.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def))
+	return err
+}
+
 func (d *DB) seedBuiltins(ctx context.Context) error {
 	// Built-in templates
 	type tmpl struct {
@@ -268,26 +490,30 @@ func (d *DB) IsAdmin(ctx context.Context, userID int64) (bool, bool, error) {
 }
 
 func (d *DB) AddAdmin(ctx context.Context, userID int64, super bool) error {
-	isSuper := 0
+	role := RoleAdmin
 	if super {
-		isSuper = 1
+		role = RoleSuper
 	}
-	_, err := d.sql.ExecContext(ctx, `INSERT OR REPLACE INTO admins(user_id,is_super,created_at) VALUES(?,?,?)`, userID, isSuper, time.Now().Unix())
-	return err
+	return d.SetAdminRole(ctx, userID, role)
 }
 
 func (d *DB) RemoveAdmin(ctx context.Context, userID int64) error {
+	old := d.column(ctx, `SELECT role FROM admins WHERE user_id=?`, userID)
 	_, err := d.sql.ExecContext(ctx, `DELETE FROM admins WHERE user_id=?`, userID)
+	if err == nil {
+		d.record(ctx, 0, fmt.Sprintf("admin:%d.role", userID), old, "")
+	}
 	return err
 }
 
 type Admin struct {
 	UserID  int64
 	IsSuper bool
+	Role    string
 }
 
 func (d *DB) ListAdmins(ctx context.Context) ([]Admin, error) {
-	rows, err := d.sql.QueryContext(ctx, `SELECT user_id,is_super FROM admins ORDER BY is_super DESC, user_id ASC`)
+	rows, err := d.sql.QueryContext(ctx, `SELECT user_id,is_super,role FROM admins ORDER BY is_super DESC, user_id ASC`)
 	if err != nil {
 		return nil, err
 	}
@@ -296,7 +522,7 @@ func (d *DB) ListAdmins(ctx context.Context) ([]Admin, error) {
 	for rows.Next() {
 		var a Admin
 		var isSuper int
-		if err := rows.Scan(&a.UserID, &isSuper); err != nil {
+		if err := rows.Scan(&a.UserID, &isSuper, &a.Role); err != nil {
 			return nil, err
 		}
 		a.IsSuper = isSuper == 1
@@ -311,6 +537,10 @@ type Chat struct {
 	Type     string // group/supergroup/channel
 	Approved bool
 	Enabled  bool
+	// Problem is why the bot can't post here ("" if fine, see ProblemLeft ...);
+	// ProblemAt is when it was detected.
+	Problem   string
+	ProblemAt sql.NullInt64
 }
 
 func (d *DB) UpsertChat(ctx context.Context, chatID int64, title, typ string) error {
@@ -379,7 +609,12 @@ func (d *DB) SetChatApproved(ctx context.Context, chatID int64, approved bool) e
 	if approved {
 		val = 1
 	}
-	_, err := d.sql.ExecContext(ctx, `UPDATE chats SET approved=?, updated_at=? WHERE chat_id=?`, val, time.Now().Unix(), chatID)
+	old := d.column(ctx, `SELECT approved FROM chats WHERE chat_id=?`, chatID)
+	// A decision either way ends the pending period.
+	_, err := d.sql.ExecContext(ctx, `UPDATE chats SET approved=?, requested_at=NULL, updated_at=? WHERE chat_id=?`, val, time.Now().Unix(), chatID)
+	if err == nil {
+		d.record(ctx, chatID, "approved", old, strconv.Itoa(val))
+	}
 	return err
 }
 
@@ -388,15 +623,19 @@ func (d *DB) SetChatEnabled(ctx context.Context, chatID int64, enabled bool) err
 	if enabled {
 		val = 1
 	}
+	old := d.column(ctx, `SELECT enabled FROM chats WHERE chat_id=?`, chatID)
 	_, err := d.sql.ExecContext(ctx, `UPDATE chats SET enabled=?, updated_at=? WHERE chat_id=?`, val, time.Now().Unix(), chatID)
+	if err == nil {
+		d.record(ctx, chatID, "enabled", old, strconv.Itoa(val))
+	}
 	return err
 }
 
 func (d *DB) GetChat(ctx context.Context, chatID int64) (Chat, error) {
 	var c Chat
 	var approved, enabled int
-	err := d.sql.QueryRowContext(ctx, `SELECT chat_id,title,type,approved,enabled FROM chats WHERE chat_id=?`, chatID).
-		Scan(&c.ChatID, &c.Title, &c.Type, &approved, &enabled)
+	err := d.sql.QueryRowContext(ctx, `SELECT chat_id,title,type,approved,enabled,problem,problem_at FROM chats WHERE chat_id=?`, chatID).
+		Scan(&c.ChatID, &c.Title, &c.Type, &approved, &enabled, &c.Problem, &c.ProblemAt)
 	if err != nil {
 		return Chat{}, err
 	}
@@ -406,7 +645,7 @@ func (d *DB) GetChat(ctx context.Context, chatID int64) (Chat, error) {
 }
 
 func (d *DB) ListChats(ctx context.Context) ([]Chat, error) {
-	rows, err := d.sql.QueryContext(ctx, `SELECT chat_id,title,type,approved,enabled FROM chats ORDER BY approved ASC, type DESC, title COLLATE NOCASE ASC`)
+	rows, err := d.sql.QueryContext(ctx, `SELECT chat_id,title,type,approved,enabled,problem,problem_at FROM chats ORDER BY approved ASC, type DESC, title COLLATE NOCASE ASC`)
 	if err != nil {
 		return nil, err
 	}
@@ -415,7 +654,7 @@ func (d *DB) ListChats(ctx context.Context) ([]Chat, error) {
 	for rows.Next() {
 		var c Chat
 		var approved, enabled int
-		if err := rows.Scan(&c.ChatID, &c.Title, &c.Type, &approved, &enabled); err != nil {
+		if err := rows.Scan(&c.ChatID, &c.Title, &c.Type, &approved, &enabled, &c.Problem, &c.ProblemAt); err != nil {
 			return nil, err
 		}
 		c.Approved = approved == 1
@@ -440,19 +679,53 @@ type ChatSettings struct {
 	TriggerItems        []string
 	TriggerThresholdType  string
 	TriggerThresholdValue float64
-
-	PostMode  string // new/edit
+	// ItemThresholds overrides the chat-wide threshold per trigger item (see ThresholdFor).
+	ItemThresholds map[string]TriggerThreshold
+	// TriggerCooldownMinutes is the minimum gap between trigger posts;
+	// TriggerMaxSilenceMinutes posts anyway after that long without a post (0 = off).
+	TriggerCooldownMinutes   int
+	TriggerMaxSilenceMinutes int
+	// TriggerBaseline is what triggers and arrows compare against:
+	// "last_post", "day_open" or "minutes_ago" (TriggerBaselineMinutes back, from price history).
+	TriggerBaseline        string
+	TriggerBaselineMinutes int
+
+	PostMode  string // new/edit/repost/pin
 	PriceMode string // sell/buy/both
 	Digits    string // en/fa
 
 	ShowSameArrow bool
+	// EditIgnoreTime skips edits whose only change is the date/time placeholders.
+	EditIgnoreTime bool
+
+	// CommandsEnabled lets group members use /price, /p, /usd ... in the chat,
+	// at most once per CommandsCooldownSeconds.
+	CommandsEnabled         bool
+	CommandsCooldownSeconds int
 
 	TemplateID string
 
 	LastPostMessageID sql.NullInt64
+	// LastPostMedia is the media of the last board ("" for text, "type:file_id"
+	// otherwise); NULL for boards posted before it was tracked.
+	LastPostMedia sql.NullString
+	// LastPostHash is the render.Output hash of the last board (see render.Output.Hash).
+	LastPostHash sql.NullString
 	LastPostTime      sql.NullInt64
 	LastFetchTime     sql.NullInt64
 	LastError         sql.NullString
+
+	// NextDueAt is when the next scheduled post is due (unix seconds).
+	// NULL means "compute from the interval".
+	NextDueAt sql.NullInt64
+
+	// MediaIndex is the position in a rotating template's image list of the
+	// image on the current board.
+	MediaIndex int
+
+	// LastTriggerTime is when the last trigger update was sent next to a
+	// pinned board (pin mode); trigger cooldown and max silence count from it.
+	LastTriggerTime sql.NullInt64
 }
 
 func (d *DB) GetChatSettings(ctx context.Context, chatID int64) (ChatSettings, error) {
@@ -460,22 +733,31 @@ func (d *DB) GetChatSettings(ctx context.Context, chatID int64) (ChatSettings, e
 	s.ChatID = chatID
 	var downtimeEnabled int
 	var showSame int
+	var ignoreTime int
+	var commands int
 	var trigJSON string
 	err := d.sql.QueryRowContext(ctx, `SELECT source_provider,source_method,interval_minutes,downtime_enabled,downtime_start,downtime_end,
-		trigger_items,trigger_threshold_type,trigger_threshold_value,post_mode,price_mode,digits,show_same_arrow,template_id,
-		last_post_message_id,last_post_time,last_fetch_time,last_error
+		trigger_items,trigger_threshold_type,trigger_threshold_value,trigger_cooldown_minutes,trigger_max_silence_minutes,trigger_baseline,trigger_baseline_minutes,post_mode,price_mode,digits,show_same_arrow,edit_ignore_time,commands_enabled,commands_cooldown_seconds,template_id,
+		last_post_message_id,last_post_media,last_post_hash,last_post_time,last_fetch_time,last_error,next_due_at,media_index,last_trigger_time
 		FROM chat_settings WHERE chat_id=?`, chatID).
 		Scan(&s.SourceProvider, &s.SourceMethod, &s.IntervalMinutes,
 			&downtimeEnabled, &s.DowntimeStart, &s.DowntimeEnd,
-			&trigJSON, &s.TriggerThresholdType, &s.TriggerThresholdValue,
-			&s.PostMode, &s.PriceMode, &s.Digits, &showSame, &s.TemplateID,
-			&s.LastPostMessageID, &s.LastPostTime, &s.LastFetchTime, &s.LastError)
+			&trigJSON, &s.TriggerThresholdType, &s.TriggerThresholdValue, &s.TriggerCooldownMinutes, &s.TriggerMaxSilenceMinutes,
+			&s.TriggerBaseline, &s.TriggerBaselineMinutes,
+			&s.PostMode, &s.PriceMode, &s.Digits, &showSame, &ignoreTime, &commands, &s.CommandsCooldownSeconds, &s.TemplateID,
+			&s.LastPostMessageID, &s.LastPostMedia, &s.LastPostHash, &s.LastPostTime, &s.LastFetchTime, &s.LastError, &s.NextDueAt, &s.MediaIndex, &s.LastTriggerTime)
 	if err != nil {
 		return ChatSettings{}, err
 	}
 	s.DowntimeEnabled = downtimeEnabled == 1
 	s.ShowSameArrow = showSame == 1
+	s.EditIgnoreTime = ignoreTime == 1
+	s.CommandsEnabled = commands == 1
 	_ = json.Unmarshal([]byte(trigJSON), &s.TriggerItems)
+	s.ItemThresholds, err = d.getItemThresholds(ctx, chatID)
+	if err != nil {
+		return ChatSettings{}, err
+	}
 	return s, nil
 }
 
@@ -484,8 +766,11 @@ func (d *DB) UpdateChatSetting(ctx context.Context, chatID int64, key string, va
 		"source_provider": true, "source_method": true, "interval_minutes": true,
 		"downtime_enabled": true, "downtime_start": true, "downtime_end": true,
 		"trigger_items": true, "trigger_threshold_type": true, "trigger_threshold_value": true,
+		"trigger_cooldown_minutes": true, "trigger_max_silence_minutes": true,
+		"trigger_baseline": true, "trigger_baseline_minutes": true,
 		"post_mode": true, "price_mode": true, "digits": true, "show_same_arrow": true,
-		"template_id": true,
+		"edit_ignore_time": true, "template_id": true,
+		"commands_enabled": true, "commands_cooldown_seconds": true,
 	}
 	if !allowed[key] {
 		return fmt.Errorf("invalid setting key: %s", key)
@@ -496,7 +781,7 @@ func (d *DB) UpdateChatSetting(ctx context.Context, chatID int64, key string, va
 		b, _ := json.Marshal(value)
 		value = string(b)
 	}
-	if key == "downtime_enabled" || key == "show_same_arrow" {
+	if key == "downtime_enabled" || key == "show_same_arrow" || key == "edit_ignore_time" || key == "commands_enabled" {
 		// accept bool
 		if bv, ok := value.(bool); ok {
 			if bv {
@@ -506,7 +791,19 @@ func (d *DB) UpdateChatSetting(ctx context.Context, chatID int64, key string, va
 			}
 		}
 	}
-	_, err := d.sql.ExecContext(ctx, fmt.Sprintf(`UPDATE chat_settings SET %s=? WHERE chat_id=?`, key), value, chatID)
+	old := ""
+	if auditing(ctx) {
+		old = d.column(ctx, fmt.Sprintf(`SELECT %s FROM chat_settings WHERE chat_id=?`, key), chatID)
+	}
+	q := fmt.Sprintf(`UPDATE chat_settings SET %s=? WHERE chat_id=?`, key)
+	if key == "interval_minutes" {
+		// The old due time belongs to the old interval.
+		q = `UPDATE chat_settings SET interval_minutes=?, next_due_at=NULL WHERE chat_id=?`
+	}
+	_, err := d.sql.ExecContext(ctx, q, value, chatID)
+	if err == nil {
+		d.record(ctx, chatID, key, old, auditValue(value))
+	}
 	return err
 }
 
@@ -563,6 +860,9 @@ func (d *DB) ToggleChatItem(ctx context.Context, chatID int64, itemID string) (b
 		next = 0
 	}
 	_, err = d.sql.ExecContext(ctx, `UPDATE chat_items SET enabled=? WHERE chat_id=? AND item_id=?`, next, chatID, itemID)
+	if err == nil {
+		d.record(ctx, chatID, "item:"+itemID+".enabled", strconv.Itoa(cur), strconv.Itoa(next))
+	}
 	return next == 1, err
 }
 
@@ -599,6 +899,9 @@ func (d *DB) MoveChatItem(ctx context.Context, chatID int64, itemID string, dir
 		return err
 	}
 	_, err = d.sql.ExecContext(ctx, `UPDATE chat_items SET position=? WHERE chat_id=? AND item_id=?`, a.Position, chatID, b.ItemID)
+	if err == nil {
+		d.record(ctx, chatID, "item:"+itemID+".position", strconv.Itoa(idx+1), strconv.Itoa(swapWith+1))
+	}
 	return err
 }
 
@@ -619,7 +922,7 @@ type Template struct {
 	Name        string
 	Description string
 	Body        string
-	MediaType   string // "", "photo", "video"
+	MediaType   string // "", photo/video/animation/document, or album/rotate (see Template.Media)
 	MediaFileID string
 	IsBuiltin   bool
 	CreatedBy   int64
@@ -653,31 +956,80 @@ func (d *DB) CreateTemplate(ctx context.Context, name, desc, body string, create
 	if err != nil {
 		return Template{}, err
 	}
+	d.record(ctx, 0, "template:"+id+".name", "", name)
+	d.record(ctx, 0, "template:"+id+".body", "", body)
+	if err := d.addRevision(ctx, id); err != nil {
+		return Template{}, err
+	}
 	return d.GetTemplate(ctx, id)
 }
 
 func (d *DB) UpdateTemplateBody(ctx context.Context, templateID, body string) error {
-	_, err := d.sql.ExecContext(ctx, `UPDATE templates SET body=? WHERE template_id=?`, body, templateID)
-	return err
+	if err := d.editableTemplate(ctx, templateID); err != nil {
+		return err
+	}
+	old := d.column(ctx, `SELECT body FROM templates WHERE template_id=?`, templateID)
+	if err := d.baselineRevision(ctx, templateID); err != nil {
+		return err
+	}
+	if _, err := d.sql.ExecContext(ctx, `UPDATE templates SET body=? WHERE template_id=?`, body, templateID); err != nil {
+		return err
+	}
+	d.record(ctx, 0, "template:"+templateID+".body", old, body)
+	return d.addRevision(ctx, templateID)
 }
 
 func (d *DB) UpdateTemplateMeta(ctx context.Context, templateID, name, desc string) error {
+	if err := d.editableTemplate(ctx, templateID); err != nil {
+		return err
+	}
+	old := d.column(ctx, `SELECT name FROM templates WHERE template_id=?`, templateID)
 	_, err := d.sql.ExecContext(ctx, `UPDATE templates SET name=?, description=? WHERE template_id=?`, name, desc, templateID)
+	if err == nil {
+		d.record(ctx, 0, "template:"+templateID+".name", old, name)
+	}
 	return err
 }
 
 func (d *DB) SetTemplateMedia(ctx context.Context, templateID, mediaType, fileID string) error {
-	_, err := d.sql.ExecContext(ctx, `UPDATE templates SET media_type=?, media_file_id=? WHERE template_id=?`, mediaType, fileID, templateID)
-	return err
+	if err := d.editableTemplate(ctx, templateID); err != nil {
+		return err
+	}
+	if err := ValidateMedia(mediaType, Template{MediaType: mediaType, MediaFileID: fileID}.Media()); err != nil {
+		return err
+	}
+	old := d.column(ctx, `SELECT media_type FROM templates WHERE template_id=?`, templateID)
+	if err := d.baselineRevision(ctx, templateID); err != nil {
+		return err
+	}
+	if _, err := d.sql.ExecContext(ctx, `UPDATE templates SET media_type=?, media_file_id=? WHERE template_id=?`, mediaType, fileID, templateID); err != nil {
+		return err
+	}
+	d.record(ctx, 0, "template:"+templateID+".media", old, mediaType+":"+fileID)
+	return d.addRevision(ctx, templateID)
 }
 
 func (d *DB) ClearTemplateMedia(ctx context.Context, templateID string) error {
-	_, err := d.sql.ExecContext(ctx, `UPDATE templates SET media_type='', media_file_id='' WHERE template_id=?`, templateID)
-	return err
+	if err := d.editableTemplate(ctx, templateID); err != nil {
+		return err
+	}
+	old := d.column(ctx, `SELECT media_type FROM templates WHERE template_id=?`, templateID)
+	if err := d.baselineRevision(ctx, templateID); err != nil {
+		return err
+	}
+	if _, err := d.sql.ExecContext(ctx, `UPDATE templates SET media_type='', media_file_id='' WHERE template_id=?`, templateID); err != nil {
+		return err
+	}
+	d.record(ctx, 0, "template:"+templateID+".media", old, "")
+	return d.addRevision(ctx, templateID)
 }
 
 func (d *DB) SetChatTemplate(ctx context.Context, chatID int64, templateID string) error {
+	old := d.column(ctx, `SELECT template_id FROM chat_settings WHERE chat_id=?`, chatID)
 	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET template_id=? WHERE chat_id=?`, templateID, chatID)
+	if err == nil {
+		d.record(ctx, chatID, "template_id", old, templateID)
+	}
 	return err
 }
 
@@ -702,8 +1054,32 @@ func (d *DB) SetLastValue(ctx context.Context, chatID int64, itemID string, valu
 	return err
 }
 
-func (d *DB) UpdateLastPost(ctx context.Context, chatID int64, messageID int, at time.Time) error {
-	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET last_post_message_id=?, last_post_time=? WHERE chat_id=?`, messageID, at.Unix(), chatID)
+func (d *DB) UpdateLastPost(ctx context.Context, chatID int64, messageID int, media string, at time.Time) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET last_post_message_id=?, last_post_media=?, last_post_time=? WHERE chat_id=?`, messageID, media, at.Unix(), chatID)
+	return err
+}
+
+// SetLastPostHash stores the hash of the rendered board last sent to chatID.
+func (d *DB) SetLastPostHash(ctx context.Context, chatID int64, hash string) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET last_post_hash=? WHERE chat_id=?`, hash, chatID)
+	return err
+}
+
+// SetLastTriggerTime stores when a pin-mode trigger update was sent.
+func (d *DB) SetLastTriggerTime(ctx context.Context, chatID int64, at time.Time) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET last_trigger_time=? WHERE chat_id=?`, at.Unix(), chatID)
+	return err
+}
+
+// SetMediaIndex stores which image of a rotating template the chat's board shows.
+func (d *DB) SetMediaIndex(ctx context.Context, chatID int64, index int) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET media_index=? WHERE chat_id=?`, index, chatID)
+	return err
+}
+
+// SetNextDue stores when the next scheduled post for chatID is due.
+func (d *DB) SetNextDue(ctx context.Context, chatID int64, at time.Time) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET next_due_at=? WHERE chat_id=?`, at.Unix(), chatID)
 	return err
 }
 
@@ -716,6 +1092,12 @@ func (d *DB) UpdateFetchHealth(ctx context.Context, chatID int64, fetchedAt time
 	return err
 }
 
+// SetLastError records a posting problem for the status panel without touching last_fetch_time.
+func (d *DB) SetLastError(ctx context.Context, chatID int64, errMsg string) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chat_settings SET last_error=? WHERE chat_id=?`, errMsg, chatID)
+	return err
+}
+
 func (d *DB) GetGlobalSetting(ctx context.Context, key string) (string, bool, error) {
 	var v string
 	err := d.sql.QueryRowContext(ctx, `SELECT value FROM global_settings WHERE key=?`, key).Scan(&v)
@@ -729,97 +1111,21 @@ func (d *DB) GetGlobalSetting(ctx context.Context, key string) (string, bool, er
 }
 
 func (d *DB) SetGlobalSetting(ctx context.Context, key, value string) error {
+	old := d.column(ctx, `SELECT value FROM global_settings WHERE key=?`, key)
 	_, err := d.sql.ExecContext(ctx, `INSERT INTO global_settings(key,value) VALUES(?,?) ON CONFLICT(key) DO UPDATE SET value=excluded.value`, key, value)
-	return err
-}
-
-// ExportChatSettings returns a JSON blob that can be imported into another chat.
-func (d *DB) ExportChatSettings(ctx context.Context, chatID int64) ([]byte, error) {
-	s, err := d.GetChatSettings(ctx, chatID)
-	if err != nil {
-		return nil, err
-	}
-	itemsList, err := d.ListChatItems(ctx, chatID)
-	if err != nil {
-		return nil, err
-	}
-	// Keep stable export order
-	sort.Slice(itemsList, func(i, j int) bool { return itemsList[i].Position < itemsList[j].Position })
-
-	payload := map[string]any{
-		"version": 1,
-		"settings": map[string]any{
-			"source_provider":          s.SourceProvider,
-			"source_method":            s.SourceMethod,
-			"interval_minutes":         s.IntervalMinutes,
-			"downtime_enabled":         s.DowntimeEnabled,
-			"downtime_start":           s.DowntimeStart,
-			"downtime_end":             s.DowntimeEnd,
-			"trigger_items":            s.TriggerItems,
-			"trigger_threshold_type":   s.TriggerThresholdType,
-			"trigger_threshold_value":  s.TriggerThresholdValue,
-			"post_mode":                s.PostMode,
-			"price_mode":               s.PriceMode,
-			"digits":                   s.Digits,
-			"show_same_arrow":          s.ShowSameArrow,
-			"template_id":              s.TemplateID,
-		},
-		"items": itemsList,
-	}
-	return json.MarshalIndent(payload, "", "  ")
-}
-
-func (d *DB) ImportChatSettings(ctx context.Context, chatID int64, data []byte) error {
-	var payload struct {
-		Version  int `json:"version"`
-		Settings map[string]any `json:"settings"`
-		Items    []ChatItem `json:"items"`
-	}
-	if err := json.Unmarshal(data, &payload); err != nil {
-		return err
-	}
-	if payload.Version != 1 {
-		return fmt.Errorf("unsupported settings version: %d", payload.Version)
-	}
-	// Apply settings keys we know
-	for k, v := range payload.Settings {
-		switch k {
-		case "source_provider","source_method","interval_minutes","downtime_start","downtime_end","post_mode","price_mode","digits","template_id","trigger_threshold_type":
-			_ = d.UpdateChatSetting(ctx, chatID, k, v)
-		case "downtime_enabled","show_same_arrow":
-			if b, ok := v.(bool); ok {
-				_ = d.UpdateChatSetting(ctx, chatID, k, b)
+	if err == nil && old != value {
+		if secretSettings[key] {
+			if old != "" {
+				old = "(hidden)"
 			}
-		case "trigger_items":
-			// JSON decode into []string
-			b, _ := json.Marshal(v)
-			var arr []string
-			_ = json.Unmarshal(b, &arr)
-			_ = d.UpdateChatSetting(ctx, chatID, "trigger_items", arr)
-		case "trigger_threshold_value":
-			// could be float64
-			switch n := v.(type) {
-			case float64:
-				_ = d.UpdateChatSetting(ctx, chatID, k, n)
-			case int:
-				_ = d.UpdateChatSetting(ctx, chatID, k, float64(n))
-			}
-		}
-	}
-	// Apply items ordering
-	for _, it := range payload.Items {
-		en := 0
-		if it.Enabled {
-			en = 1
+			value = "(hidden, changed)"
 		}
-		_, _ = d.sql.ExecContext(ctx, `INSERT INTO chat_items(chat_id,item_id,position,enabled) VALUES(?,?,?,?)
-			ON CONFLICT(chat_id,item_id) DO UPDATE SET position=excluded.position, enabled=excluded.enabled`,
-			chatID, it.ItemID, it.Position, en)
+		d.record(ctx, 0, "global:"+key, old, value)
 	}
-	// normalize positions to avoid duplicates
-	return d.normalizePositions(ctx, chatID)
+	return err
 }
 
+// ExportChatSettings returns a JSON blob that can be imported into another chat.
 func (d *DB) normalizePositions(ctx context.Context, chatID int64) error {
 	itemsList, err := d.ListChatItems(ctx, chatID)
 	if err != nil {
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// DigestPeriods lists the supported digest periods in menu order.
+var DigestPeriods = []string{"daily", "weekly", "monthly"}
+
+// Digest is a per-chat open/close/high/low summary post for one period.
+type Digest struct {
+	ChatID      int64
+	Period      string // daily/weekly/monthly
+	Enabled     bool
+	AtTime      string // HH:MM Tehran
+	Template    string // empty = default
+	LastSentKey string // period key of the last sent digest (prevents duplicates)
+}
+
+// GetDigest returns the digest config of a chat, or the defaults if none was saved.
+func (d *DB) GetDigest(ctx context.Context, chatID int64, period string) (Digest, error) {
+	dg := Digest{ChatID: chatID, Period: period, AtTime: "23:00"}
+	var enabled int
+	err := d.sql.QueryRowContext(ctx, `SELECT enabled,at_time,template,last_sent_key FROM chat_digests WHERE chat_id=? AND period=?`, chatID, period).
+		Scan(&enabled, &dg.AtTime, &dg.Template, &dg.LastSentKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return dg, nil
+	}
+	if err != nil {
+		return Digest{}, err
+	}
+	dg.Enabled = enabled == 1
+	return dg, nil
+}
+
+// ListEnabledDigests returns enabled digests of all chats (for the scheduler).
+func (d *DB) ListEnabledDigests(ctx context.Context) ([]Digest, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT chat_id,period,at_time,template,last_sent_key FROM chat_digests WHERE enabled=1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Digest
+	for rows.Next() {
+		dg := Digest{Enabled: true}
+		if err := rows.Scan(&dg.ChatID, &dg.Period, &dg.AtTime, &dg.Template, &dg.LastSentKey); err != nil {
+			return nil, err
+		}
+		out = append(out, dg)
+	}
+	return out, rows.Err()
+}
+
+// UpdateDigest sets one column of a chat's digest, creating the row if needed.
+func (d *DB) UpdateDigest(ctx context.Context, chatID int64, period, key string, value any) error {
+	allowed := map[string]bool{"enabled": true, "at_time": true, "template": true, "last_sent_key": true}
+	if !allowed[key] {
+		return fmt.Errorf("invalid digest key: %s", key)
+	}
+	if bv, ok := value.(bool); ok {
+		value = boolInt(bv)
+	}
+	if _, err := d.sql.ExecContext(ctx, `INSERT OR IGNORE INTO chat_digests(chat_id,period) VALUES(?,?)`, chatID, period); err != nil {
+		return err
+	}
+	old := ""
+	if auditing(ctx) {
+		old = d.column(ctx, fmt.Sprintf(`SELECT %s FROM chat_digests WHERE chat_id=? AND period=?`, key), chatID, period)
+	}
+	_, err := d.sql.ExecContext(ctx, fmt.Sprintf(`UPDATE chat_digests SET %s=? WHERE chat_id=? AND period=?`, key), value, chatID, period)
+	if err == nil {
+		d.record(ctx, chatID, "digest:"+period+"."+key, old, auditValue(value))
+	}
+	return err
+}
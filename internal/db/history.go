@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PricePoint is one recorded quote of an item from a provider/method.
+type PricePoint struct {
+	ItemID string
+	At     int64
+	Sell   *float64
+	Buy    *float64
+}
+
+// RecordPrices stores a fetched snapshot in price_history. Re-recording the
+// same snapshot (same fetch time) is a no-op.
+func (d *DB) RecordPrices(ctx context.Context, provider, method string, at time.Time, points []PricePoint) error {
+	tx, err := d.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, p := range points {
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO price_history(provider,method,item_id,at,sell,buy) VALUES(?,?,?,?,?,?)`,
+			provider, method, p.ItemID, at.Unix(), nullFloat(p.Sell), nullFloat(p.Buy))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PricesAt returns, per item, the latest point recorded at or before t.
+func (d *DB) PricesAt(ctx context.Context, provider, method string, itemIDs []string, t time.Time) (map[string]PricePoint, error) {
+	return d.pricePerItem(ctx, `SELECT item_id,at,sell,buy FROM price_history
+		WHERE provider=? AND method=? AND item_id=? AND at<=? ORDER BY at DESC LIMIT 1`, provider, method, itemIDs, t)
+}
+
+// FirstPricesSince returns, per item, the earliest point recorded at or after t.
+func (d *DB) FirstPricesSince(ctx context.Context, provider, method string, itemIDs []string, t time.Time) (map[string]PricePoint, error) {
+	return d.pricePerItem(ctx, `SELECT item_id,at,sell,buy FROM price_history
+		WHERE provider=? AND method=? AND item_id=? AND at>=? ORDER BY at ASC LIMIT 1`, provider, method, itemIDs, t)
+}
+
+func (d *DB) pricePerItem(ctx context.Context, q, provider, method string, itemIDs []string, t time.Time) (map[string]PricePoint, error) {
+	out := map[string]PricePoint{}
+	for _, id := range itemIDs {
+		var p PricePoint
+		var sell, buy sql.NullFloat64
+		err := d.sql.QueryRowContext(ctx, q, provider, method, id, t.Unix()).Scan(&p.ItemID, &p.At, &sell, &buy)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.Sell = floatPtr(sell)
+		p.Buy = floatPtr(buy)
+		out[id] = p
+	}
+	return out, nil
+}
+
+// PricesBetween returns the points of each item in [from, to], oldest first.
+func (d *DB) PricesBetween(ctx context.Context, provider, method string, itemIDs []string, from, to time.Time) (map[string][]PricePoint, error) {
+	out := map[string][]PricePoint{}
+	if len(itemIDs) == 0 {
+		return out, nil
+	}
+	args := []any{provider, method, from.Unix(), to.Unix()}
+	for _, id := range itemIDs {
+		args = append(args, id)
+	}
+	rows, err := d.sql.QueryContext(ctx, `SELECT item_id,at,sell,buy FROM price_history
+		WHERE provider=? AND method=? AND at>=? AND at<=? AND item_id IN (`+placeholders(len(itemIDs))+`) ORDER BY at ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p PricePoint
+		var sell, buy sql.NullFloat64
+		if err := rows.Scan(&p.ItemID, &p.At, &sell, &buy); err != nil {
+			return nil, err
+		}
+		p.Sell = floatPtr(sell)
+		p.Buy = floatPtr(buy)
+		out[p.ItemID] = append(out[p.ItemID], p)
+	}
+	return out, rows.Err()
+}
+
+// PruneHistory deletes price points older than before.
+func (d *DB) PruneHistory(ctx context.Context, before time.Time) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM price_history WHERE at<?`, before.Unix())
+	return err
+}
+
+func nullFloat(f *float64) any {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+func floatPtr(n sql.NullFloat64) *float64 {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Float64
+	return &v
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Lease is a named, expiring lock row used for single-instance leadership.
+type Lease struct {
+	Name        string
+	Holder      string
+	ExpiresAt   int64
+	HeartbeatAt int64
+}
+
+// AcquireLease takes or renews the lease `name` for holder. It succeeds when
+// the lease is free, expired, or already held by holder. prev is the row as
+// it was before the call (zero Lease if there was none).
+func (d *DB) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (ok bool, prev Lease, err error) {
+	tx, err := d.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return false, Lease{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx, `SELECT name,holder,expires_at,heartbeat_at FROM leases WHERE name=?`, name).
+		Scan(&prev.Name, &prev.Holder, &prev.ExpiresAt, &prev.HeartbeatAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, Lease{}, err
+	}
+
+	now := time.Now()
+	if prev.Holder != "" && prev.Holder != holder && prev.ExpiresAt > now.Unix() {
+		return false, prev, nil
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO leases(name,holder,expires_at,heartbeat_at) VALUES(?,?,?,?)
+		 ON CONFLICT(name) DO UPDATE SET holder=excluded.holder, expires_at=excluded.expires_at, heartbeat_at=excluded.heartbeat_at`,
+		name, holder, now.Add(ttl).Unix(), now.Unix())
+	if err != nil {
+		return false, prev, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, prev, err
+	}
+	return true, prev, nil
+}
+
+// ReleaseLease gives up the lease if holder still owns it.
+func (d *DB) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM leases WHERE name=? AND holder=?`, name, holder)
+	return err
+}
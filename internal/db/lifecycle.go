@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Chat problems: why the bot can no longer post in a chat. Left and kicked
+// chats are orphaned (the bot is not a member any more).
+const (
+	ProblemLeft       = "left"
+	ProblemKicked     = "kicked"
+	ProblemRestricted = "restricted" // group member without send rights
+	ProblemNoRights   = "no_rights"  // channel: not an admin, or can't post
+)
+
+// Orphaned reports whether the bot has been removed from the chat.
+func (c Chat) Orphaned() bool {
+	return c.Problem == ProblemLeft || c.Problem == ProblemKicked
+}
+
+// SetChatProblem records (or with "" clears) why the bot can't post in chatID.
+// The detection time is kept while the problem stays the same.
+func (d *DB) SetChatProblem(ctx context.Context, chatID int64, problem string) error {
+	old := d.column(ctx, `SELECT problem FROM chats WHERE chat_id=?`, chatID)
+	if old == problem {
+		return nil
+	}
+	var at any
+	if problem != "" {
+		at = time.Now().Unix()
+	}
+	_, err := d.sql.ExecContext(ctx, `UPDATE chats SET problem=?, problem_at=?, updated_at=? WHERE chat_id=?`, problem, at, time.Now().Unix(), chatID)
+	if err == nil {
+		d.record(ctx, chatID, "problem", old, problem)
+	}
+	return err
+}
+
+// DeleteChat forgets a chat and, through the foreign keys, all its settings.
+func (d *DB) DeleteChat(ctx context.Context, chatID int64) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM chats WHERE chat_id=?`, chatID)
+	if err == nil {
+		d.record(ctx, chatID, "chat", "", "deleted")
+	}
+	return err
+}
+
+// chatTables hold per-chat rows that follow a chat to its new ID on migration.
+var chatTables = []string{
+	"chat_settings", "chat_items", "chat_last_values", "chat_trigger_thresholds",
+	"alert_rules", "chat_digests", "chat_owner_policy", "chat_profiles", "admin_chats", "audit_log",
+}
+
+// MigrateChat moves a group that was upgraded to a supergroup to its new ID,
+// keeping approval and all settings. Message IDs of the old chat mean nothing
+// in the new one, so tracked messages and the board anchor are dropped.
+// It returns false if oldID is unknown (e.g. already migrated).
+func (d *DB) MigrateChat(ctx context.Context, oldID, newID int64) (bool, error) {
+	tx, err := d.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM chats WHERE chat_id=?`, oldID).Scan(&n); err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, nil
+	}
+	// The new chat may already have been registered with defaults (its own
+	// my_chat_member update); the migrated settings win.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chats WHERE chat_id=?`, newID); err != nil {
+		return false, err
+	}
+	now := time.Now().Unix()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO chats(chat_id,title,type,approved,enabled,created_at,updated_at,problem,problem_at,requested_at)
+		SELECT ?,title,'supergroup',approved,enabled,created_at,?,'',NULL,requested_at FROM chats WHERE chat_id=?`, newID, now, oldID); err != nil {
+		return false, err
+	}
+	for _, t := range chatTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET chat_id=? WHERE chat_id=?`, t), newID, oldID); err != nil {
+			return false, fmt.Errorf("migrate %s: %w", t, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE chat_settings SET last_post_message_id=NULL, last_post_media=NULL, last_post_hash=NULL WHERE chat_id=?`, newID); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chats WHERE chat_id=?`, oldID); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO audit_log(at,admin_id,chat_id,key,old_value,new_value) VALUES(?,0,?,'chat_id',?,?)`,
+		now, newID, fmt.Sprint(oldID), fmt.Sprint(newID)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
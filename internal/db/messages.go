@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// BotMessage is a message the bot posted in a chat (board, alert, digest).
+type BotMessage struct {
+	ChatID    int64
+	MessageID int
+	Kind      string // board/update/alert/digest
+	CreatedAt int64
+}
+
+// TrackMessage remembers a message the bot posted so it can be deleted or
+// unpinned later.
+func (d *DB) TrackMessage(ctx context.Context, chatID int64, messageID int, kind string) error {
+	_, err := d.sql.ExecContext(ctx, `INSERT OR REPLACE INTO chat_messages(chat_id,message_id,kind,created_at) VALUES(?,?,?,?)`,
+		chatID, messageID, kind, time.Now().Unix())
+	return err
+}
+
+// TrackAlbum remembers the messages of one media group; they are grouped
+// under the first message's ID (see AlbumMessages).
+func (d *DB) TrackAlbum(ctx context.Context, chatID int64, messageIDs []int, kind string) error {
+	now := time.Now().Unix()
+	for _, id := range messageIDs {
+		if _, err := d.sql.ExecContext(ctx, `INSERT OR REPLACE INTO chat_messages(chat_id,message_id,kind,created_at,group_id) VALUES(?,?,?,?,?)`,
+			chatID, id, kind, now, messageIDs[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AlbumMessages returns the tracked messages of the media group whose first
+// message is messageID, or just messageID if it is not an album.
+func (d *DB) AlbumMessages(ctx context.Context, chatID int64, messageID int) ([]int, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT message_id FROM chat_messages WHERE chat_id=? AND group_id=? ORDER BY message_id`, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		ids = []int{messageID}
+	}
+	return ids, rows.Err()
+}
+
+// ListMessages returns the tracked messages of a chat of the given kind
+// (all kinds if kind is empty), oldest first.
+func (d *DB) ListMessages(ctx context.Context, chatID int64, kind string) ([]BotMessage, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT chat_id,message_id,kind,created_at FROM chat_messages
+		WHERE chat_id=? AND (?='' OR kind=?) ORDER BY created_at ASC, message_id ASC`, chatID, kind, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BotMessage
+	for rows.Next() {
+		var m BotMessage
+		if err := rows.Scan(&m.ChatID, &m.MessageID, &m.Kind, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// ForgetMessage stops tracking a message (it was deleted or is gone).
+func (d *DB) ForgetMessage(ctx context.Context, chatID int64, messageID int) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM chat_messages WHERE chat_id=? AND message_id=?`, chatID, messageID)
+	return err
+}
+
+// PruneMessages forgets tracked messages older than before. Bots can't
+// delete messages older than 48 hours anyway.
+func (d *DB) PruneMessages(ctx context.Context, before time.Time) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM chat_messages WHERE created_at<? AND NOT EXISTS (
+		SELECT 1 FROM chat_settings s WHERE s.chat_id=chat_messages.chat_id AND s.last_post_message_id=chat_messages.message_id)`, before.Unix())
+	return err
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// OwnerPolicy controls what a chat's own Telegram admins may change through
+// the self-service menu. It is set by bot admins only.
+type OwnerPolicy struct {
+	ChatID  int64
+	Enabled bool
+	// MinInterval/MaxInterval bound the intervals owners can pick (minutes).
+	MinInterval int
+	MaxInterval int
+}
+
+// GetOwnerPolicy returns chatID's policy; chats without a row are disabled.
+func (d *DB) GetOwnerPolicy(ctx context.Context, chatID int64) (OwnerPolicy, error) {
+	p := OwnerPolicy{ChatID: chatID, MinInterval: 5, MaxInterval: 120}
+	var enabled int
+	err := d.sql.QueryRowContext(ctx, `SELECT enabled,min_interval,max_interval FROM chat_owner_policy WHERE chat_id=?`, chatID).
+		Scan(&enabled, &p.MinInterval, &p.MaxInterval)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, nil
+	}
+	if err != nil {
+		return OwnerPolicy{}, err
+	}
+	p.Enabled = enabled == 1
+	return p, nil
+}
+
+// ListOwnerChatIDs returns approved chats with self-service enabled.
+func (d *DB) ListOwnerChatIDs(ctx context.Context) ([]int64, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT p.chat_id FROM chat_owner_policy p JOIN chats c ON c.chat_id=p.chat_id
+		WHERE p.enabled=1 AND c.approved=1 ORDER BY c.title COLLATE NOCASE ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// UpdateOwnerPolicy sets one column of a chat's owner policy, creating the row if needed.
+func (d *DB) UpdateOwnerPolicy(ctx context.Context, chatID int64, key string, value any) error {
+	allowed := map[string]bool{"enabled": true, "min_interval": true, "max_interval": true}
+	if !allowed[key] {
+		return fmt.Errorf("invalid owner policy key: %s", key)
+	}
+	if bv, ok := value.(bool); ok {
+		value = boolInt(bv)
+	}
+	if _, err := d.sql.ExecContext(ctx, `INSERT OR IGNORE INTO chat_owner_policy(chat_id) VALUES(?)`, chatID); err != nil {
+		return err
+	}
+	old := d.column(ctx, fmt.Sprintf(`SELECT %s FROM chat_owner_policy WHERE chat_id=?`, key), chatID)
+	_, err := d.sql.ExecContext(ctx, fmt.Sprintf(`UPDATE chat_owner_policy SET %s=? WHERE chat_id=?`, key), value, chatID)
+	if err == nil {
+		d.record(ctx, chatID, "owner_policy."+key, old, auditValue(value))
+	}
+	return err
+}
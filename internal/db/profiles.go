@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// profileSettingKeys are the chat settings a profile carries. Everything
+// else (post mode, price mode, commands, ...) stays per chat.
+var profileSettingKeys = map[string]bool{
+	"source_provider": true, "source_method": true, "interval_minutes": true,
+	"downtime_enabled": true, "downtime_start": true, "downtime_end": true,
+	"trigger_items": true, "trigger_threshold_type": true, "trigger_threshold_value": true,
+	"trigger_cooldown_minutes": true, "trigger_max_silence_minutes": true, "trigger_thresholds": true,
+	"trigger_baseline": true, "trigger_baseline_minutes": true,
+	"template_id": true, "digits": true,
+}
+
+// Profile is a named set of chat settings (in the export format, limited to
+// profileSettingKeys) that can be applied to many chats.
+type Profile struct {
+	ID        int64
+	Name      string
+	Data      []byte
+	CreatedBy int64
+	UpdatedAt time.Time
+}
+
+// ProfileSummary is the part of a profile shown in menus.
+type ProfileSummary struct {
+	SourceProvider  string
+	SourceMethod    string
+	IntervalMinutes int
+	TemplateID      string
+	Digits          string
+	EnabledItems    int
+}
+
+func (p Profile) Summary() ProfileSummary {
+	var payload struct {
+		Settings struct {
+			SourceProvider  string `json:"source_provider"`
+			SourceMethod    string `json:"source_method"`
+			IntervalMinutes int    `json:"interval_minutes"`
+			TemplateID      string `json:"template_id"`
+			Digits          string `json:"digits"`
+		} `json:"settings"`
+		Items []ChatItem `json:"items"`
+	}
+	_ = json.Unmarshal(p.Data, &payload)
+	s := ProfileSummary{
+		SourceProvider:  payload.Settings.SourceProvider,
+		SourceMethod:    payload.Settings.SourceMethod,
+		IntervalMinutes: payload.Settings.IntervalMinutes,
+		TemplateID:      payload.Settings.TemplateID,
+		Digits:          payload.Settings.Digits,
+	}
+	for _, it := range payload.Items {
+		if it.Enabled {
+			s.EnabledItems++
+		}
+	}
+	return s
+}
+
+// profileData exports chatID's settings limited to the profile keys.
+func (d *DB) profileData(ctx context.Context, chatID int64) ([]byte, error) {
+	raw, err := d.ExportChatSettings(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	// Alert rules and digests stay per chat.
+	delete(payload, "alerts")
+	delete(payload, "digests")
+	settings, _ := payload["settings"].(map[string]any)
+	for k := range settings {
+		if !profileSettingKeys[k] {
+			delete(settings, k)
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// CreateProfileFromChat saves chatID's current settings as a new profile.
+func (d *DB) CreateProfileFromChat(ctx context.Context, name string, chatID, createdBy int64) (Profile, error) {
+	data, err := d.profileData(ctx, chatID)
+	if err != nil {
+		return Profile{}, err
+	}
+	now := time.Now().Unix()
+	res, err := d.sql.ExecContext(ctx, `INSERT INTO settings_profiles(name,data,created_by,created_at,updated_at) VALUES(?,?,?,?,?)`,
+		name, string(data), createdBy, now, now)
+	if err != nil {
+		return Profile{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Profile{}, err
+	}
+	d.record(ctx, chatID, fmt.Sprintf("profile:%d", id), "", "created: "+name)
+	return d.GetProfile(ctx, id)
+}
+
+func (d *DB) GetProfile(ctx context.Context, profileID int64) (Profile, error) {
+	var p Profile
+	var data string
+	var updated int64
+	err := d.sql.QueryRowContext(ctx, `SELECT profile_id,name,data,created_by,updated_at FROM settings_profiles WHERE profile_id=?`, profileID).
+		Scan(&p.ID, &p.Name, &data, &p.CreatedBy, &updated)
+	if err != nil {
+		return Profile{}, err
+	}
+	p.Data = []byte(data)
+	p.UpdatedAt = time.Unix(updated, 0)
+	return p, nil
+}
+
+func (d *DB) ListProfiles(ctx context.Context) ([]Profile, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT profile_id,name,data,created_by,updated_at FROM settings_profiles ORDER BY name COLLATE NOCASE ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Profile
+	for rows.Next() {
+		var p Profile
+		var data string
+		var updated int64
+		if err := rows.Scan(&p.ID, &p.Name, &data, &p.CreatedBy, &updated); err != nil {
+			return nil, err
+		}
+		p.Data = []byte(data)
+		p.UpdatedAt = time.Unix(updated, 0)
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// DeleteProfile removes a profile; chats keep their settings but are unassigned.
+func (d *DB) DeleteProfile(ctx context.Context, profileID int64) error {
+	name := d.column(ctx, `SELECT name FROM settings_profiles WHERE profile_id=?`, profileID)
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM settings_profiles WHERE profile_id=?`, profileID)
+	if err == nil {
+		d.record(ctx, 0, fmt.Sprintf("profile:%d", profileID), name, "deleted")
+	}
+	return err
+}
+
+// ChatProfile is a chat's profile assignment. Linked chats are re-applied
+// whenever the profile changes.
+type ChatProfile struct {
+	ChatID    int64
+	ProfileID int64
+	Linked    bool
+}
+
+// GetChatProfile returns chatID's assignment; ok is false if it has none.
+func (d *DB) GetChatProfile(ctx context.Context, chatID int64) (ChatProfile, bool, error) {
+	cp := ChatProfile{ChatID: chatID}
+	var linked int
+	err := d.sql.QueryRowContext(ctx, `SELECT profile_id,linked FROM chat_profiles WHERE chat_id=?`, chatID).Scan(&cp.ProfileID, &linked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cp, false, nil
+	}
+	if err != nil {
+		return cp, false, err
+	}
+	cp.Linked = linked == 1
+	return cp, true, nil
+}
+
+// ProfileChats returns the chats assigned to profileID.
+func (d *DB) ProfileChats(ctx context.Context, profileID int64) ([]ChatProfile, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT chat_id,linked FROM chat_profiles WHERE profile_id=? ORDER BY chat_id`, profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChatProfile
+	for rows.Next() {
+		cp := ChatProfile{ProfileID: profileID}
+		var linked int
+		if err := rows.Scan(&cp.ChatID, &linked); err != nil {
+			return nil, err
+		}
+		cp.Linked = linked == 1
+		out = append(out, cp)
+	}
+	return out, rows.Err()
+}
+
+// ApplyProfile writes profileID's settings into chatID and assigns it, in
+// one transaction.
+func (d *DB) ApplyProfile(ctx context.Context, chatID, profileID int64, linked bool) error {
+	return d.inTx(ctx, func(tx *DB) error {
+		p, err := tx.GetProfile(ctx, profileID)
+		if err != nil {
+			return err
+		}
+		if err := tx.ImportChatSettings(ctx, chatID, p.Data); err != nil {
+			return err
+		}
+		if _, err := tx.sql.ExecContext(ctx, `INSERT INTO chat_profiles(chat_id,profile_id,linked) VALUES(?,?,?)
+			ON CONFLICT(chat_id) DO UPDATE SET profile_id=excluded.profile_id, linked=excluded.linked`, chatID, profileID, boolInt(linked)); err != nil {
+			return err
+		}
+		tx.record(ctx, chatID, "profile", "", fmt.Sprintf("%d (%s)", profileID, p.Name))
+		return nil
+	})
+}
+
+// SetChatProfileLinked turns live-linking of chatID's profile on or off.
+func (d *DB) SetChatProfileLinked(ctx context.Context, chatID int64, linked bool) error {
+	_, err := d.sql.ExecContext(ctx, `UPDATE chat_profiles SET linked=? WHERE chat_id=?`, boolInt(linked), chatID)
+	if err == nil {
+		d.record(ctx, chatID, "profile.linked", auditValue(!linked), auditValue(linked))
+	}
+	return err
+}
+
+// UnassignProfile detaches chatID from its profile; its settings stay as they are.
+func (d *DB) UnassignProfile(ctx context.Context, chatID int64) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM chat_profiles WHERE chat_id=?`, chatID)
+	if err == nil {
+		d.record(ctx, chatID, "profile", "assigned", "")
+	}
+	return err
+}
+
+// UpdateProfileFromChat replaces profileID's settings with chatID's current
+// ones and re-applies them to every linked chat. A chat that fails does not
+// stop the others; it returns how many linked chats were updated and one
+// error per failed chat.
+func (d *DB) UpdateProfileFromChat(ctx context.Context, profileID, chatID int64) (int, []error, error) {
+	data, err := d.profileData(ctx, chatID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := d.sql.ExecContext(ctx, `UPDATE settings_profiles SET data=?, updated_at=? WHERE profile_id=?`, string(data), time.Now().Unix(), profileID); err != nil {
+		return 0, nil, err
+	}
+	d.record(ctx, chatID, fmt.Sprintf("profile:%d", profileID), "", "updated from chat")
+	chats, err := d.ProfileChats(ctx, profileID)
+	if err != nil {
+		return 0, nil, err
+	}
+	n := 0
+	var failed []error
+	for _, cp := range chats {
+		if !cp.Linked || cp.ChatID == chatID {
+			continue
+		}
+		if err := d.ApplyProfile(ctx, cp.ChatID, profileID, true); err != nil {
+			failed = append(failed, fmt.Errorf("%d: %w", cp.ChatID, err))
+			continue
+		}
+		n++
+	}
+	return n, failed, nil
+}
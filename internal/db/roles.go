@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Admin roles. Super and global admins see every chat; a chat manager only
+// the chats granted in admin_chats.
+const (
+	RoleSuper   = "super"
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+)
+
+// GetRole returns userID's role, or "" if they are not an admin.
+func (d *DB) GetRole(ctx context.Context, userID int64) (string, error) {
+	var role string
+	err := d.sql.QueryRowContext(ctx, `SELECT role FROM admins WHERE user_id=?`, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// SetAdminRole adds userID as an admin with role, or changes their role.
+func (d *DB) SetAdminRole(ctx context.Context, userID int64, role string) error {
+	switch role {
+	case RoleSuper, RoleAdmin, RoleManager:
+	default:
+		return fmt.Errorf("invalid role: %s", role)
+	}
+	old := d.column(ctx, `SELECT role FROM admins WHERE user_id=?`, userID)
+	_, err := d.sql.ExecContext(ctx, `INSERT INTO admins(user_id,is_super,role,created_at) VALUES(?,?,?,?)
+		ON CONFLICT(user_id) DO UPDATE SET is_super=excluded.is_super, role=excluded.role`,
+		userID, boolInt(role == RoleSuper), role, time.Now().Unix())
+	if err == nil {
+		d.record(ctx, 0, fmt.Sprintf("admin:%d.role", userID), old, role)
+	}
+	return err
+}
+
+// ManagedChatIDs returns the chats a chat manager may manage.
+func (d *DB) ManagedChatIDs(ctx context.Context, userID int64) ([]int64, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT chat_id FROM admin_chats WHERE user_id=? ORDER BY chat_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// SetChatManager grants (or revokes) userID's access to chatID.
+func (d *DB) SetChatManager(ctx context.Context, userID, chatID int64, grant bool) error {
+	q := `INSERT OR IGNORE INTO admin_chats(user_id,chat_id) VALUES(?,?)`
+	if !grant {
+		q = `DELETE FROM admin_chats WHERE user_id=? AND chat_id=?`
+	}
+	res, err := d.sql.ExecContext(ctx, q, userID, chatID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n > 0 {
+			d.record(ctx, chatID, fmt.Sprintf("manager:%d", userID), strconv.Itoa(1-boolInt(grant)), strconv.Itoa(boolInt(grant)))
+		}
+	}
+	return err
+}
+
+// CanManageChat reports whether userID may see and change chatID.
+func (d *DB) CanManageChat(ctx context.Context, userID, chatID int64) (bool, error) {
+	role, err := d.GetRole(ctx, userID)
+	if err != nil || role == "" {
+		return false, err
+	}
+	if role != RoleManager {
+		return true, nil
+	}
+	var n int
+	err = d.sql.QueryRowContext(ctx, `SELECT COUNT(*) FROM admin_chats WHERE user_id=? AND chat_id=?`, userID, chatID).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBuiltinTemplate is returned when changing or deleting a built-in template.
+	ErrBuiltinTemplate = errors.New("built-in templates cannot be changed; duplicate it instead")
+	// ErrTemplateInUse is returned when deleting a template some chat still uses.
+	ErrTemplateInUse = errors.New("template is in use")
+)
+
+// editableTemplate returns ErrBuiltinTemplate for built-ins.
+func (d *DB) editableTemplate(ctx context.Context, templateID string) error {
+	t, err := d.GetTemplate(ctx, templateID)
+	if err != nil {
+		return err
+	}
+	if t.IsBuiltin {
+		return ErrBuiltinTemplate
+	}
+	return nil
+}
+
+// TemplateUsage returns how many chats and saved settings profiles use
+// templateID.
+func (d *DB) TemplateUsage(ctx context.Context, templateID string) (chats, profiles int, err error) {
+	err = d.sql.QueryRowContext(ctx, `SELECT
+		(SELECT COUNT(*) FROM chat_settings WHERE template_id=?),
+		(SELECT COUNT(*) FROM settings_profiles WHERE json_extract(data,'$.settings.template_id')=?)`,
+		templateID, templateID).Scan(&chats, &profiles)
+	return chats, profiles, err
+}
+
+// DeleteTemplate removes a custom template that no chat or profile uses.
+func (d *DB) DeleteTemplate(ctx context.Context, templateID string) error {
+	if err := d.editableTemplate(ctx, templateID); err != nil {
+		return err
+	}
+	chats, profiles, err := d.TemplateUsage(ctx, templateID)
+	if err != nil {
+		return err
+	}
+	if chats > 0 || profiles > 0 {
+		return fmt.Errorf("%w by %d chats and %d profiles", ErrTemplateInUse, chats, profiles)
+	}
+	name := d.column(ctx, `SELECT name FROM templates WHERE template_id=?`, templateID)
+	_, err = d.sql.ExecContext(ctx, `DELETE FROM templates WHERE template_id=?`, templateID)
+	if err == nil {
+		d.record(ctx, 0, "template:"+templateID, name, "deleted")
+	}
+	return err
+}
+
+// DuplicateTemplate copies templateID (body, description and media) into a
+// new custom template owned by createdBy. It is also how templates are
+// shared: every admin sees all templates, and a copy is theirs to edit.
+func (d *DB) DuplicateTemplate(ctx context.Context, templateID, name string, createdBy int64) (Template, error) {
+	src, err := d.GetTemplate(ctx, templateID)
+	if err != nil {
+		return Template{}, err
+	}
+	t, err := d.CreateTemplate(ctx, name, src.Description, src.Body, createdBy)
+	if err != nil {
+		return Template{}, err
+	}
+	if src.MediaType != "" {
+		if err := d.SetTemplateMedia(ctx, t.TemplateID, src.MediaType, src.MediaFileID); err != nil {
+			return Template{}, err
+		}
+		t.MediaType, t.MediaFileID = src.MediaType, src.MediaFileID
+	}
+	return t, nil
+}
+
+// TemplateRevision is a saved state of a template's body and media.
+type TemplateRevision struct {
+	RevID       int64
+	TemplateID  string
+	Body        string
+	MediaType   string
+	MediaFileID string
+	CreatedBy   int64 // 0 = before history was kept, or the system
+	CreatedAt   int64
+}
+
+// addRevision snapshots templateID's current body and media, authored by
+// the ctx actor.
+func (d *DB) addRevision(ctx context.Context, templateID string) error {
+	author, _ := actorFrom(ctx)
+	_, err := d.sql.ExecContext(ctx, `INSERT INTO template_revisions(template_id,body,media_type,media_file_id,created_by,created_at)
+		SELECT template_id,body,media_type,media_file_id,?,? FROM templates WHERE template_id=?`,
+		author, time.Now().Unix(), templateID)
+	return err
+}
+
+// baselineRevision saves the current state of a template that predates
+// revision history, so its first edit can be rolled back.
+func (d *DB) baselineRevision(ctx context.Context, templateID string) error {
+	_, err := d.sql.ExecContext(ctx, `INSERT INTO template_revisions(template_id,body,media_type,media_file_id,created_by,created_at)
+		SELECT template_id,body,media_type,media_file_id,created_by,created_at FROM templates
+		WHERE template_id=? AND NOT EXISTS (SELECT 1 FROM template_revisions WHERE template_id=?)`,
+		templateID, templateID)
+	return err
+}
+
+// ListTemplateRevisions returns the latest limit revisions of templateID, newest first.
+func (d *DB) ListTemplateRevisions(ctx context.Context, templateID string, limit int) ([]TemplateRevision, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT rev_id,template_id,body,media_type,media_file_id,created_by,created_at
+		FROM template_revisions WHERE template_id=? ORDER BY rev_id DESC LIMIT ?`, templateID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TemplateRevision
+	for rows.Next() {
+		var r TemplateRevision
+		if err := rows.Scan(&r.RevID, &r.TemplateID, &r.Body, &r.MediaType, &r.MediaFileID, &r.CreatedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) GetTemplateRevision(ctx context.Context, revID int64) (TemplateRevision, error) {
+	var r TemplateRevision
+	err := d.sql.QueryRowContext(ctx, `SELECT rev_id,template_id,body,media_type,media_file_id,created_by,created_at
+		FROM template_revisions WHERE rev_id=?`, revID).
+		Scan(&r.RevID, &r.TemplateID, &r.Body, &r.MediaType, &r.MediaFileID, &r.CreatedBy, &r.CreatedAt)
+	return r, err
+}
+
+// RollbackTemplate restores a template's body and media from revID. The
+// rollback itself becomes the newest revision.
+func (d *DB) RollbackTemplate(ctx context.Context, revID int64) error {
+	r, err := d.GetTemplateRevision(ctx, revID)
+	if err != nil {
+		return err
+	}
+	if err := d.editableTemplate(ctx, r.TemplateID); err != nil {
+		return err
+	}
+	if _, err := d.sql.ExecContext(ctx, `UPDATE templates SET body=?, media_type=?, media_file_id=? WHERE template_id=?`,
+		r.Body, r.MediaType, r.MediaFileID, r.TemplateID); err != nil {
+		return err
+	}
+	d.record(ctx, 0, "template:"+r.TemplateID+".rollback", "", fmt.Sprintf("revision %d", revID))
+	return d.addRevision(ctx, r.TemplateID)
+}
+
+// Template media types. A template has no media (""), one file (photo,
+// video, animation, document), or a list of files: an album posted as one
+// media group, or a rotation that shows the next image on each new post.
+const (
+	MediaPhoto     = "photo"
+	MediaVideo     = "video"
+	MediaAnimation = "animation"
+	MediaDocument  = "document"
+	MediaAlbum     = "album"
+	MediaRotate    = "rotate"
+)
+
+// MaxAlbumItems is Telegram's limit on files in one media group;
+// MaxRotateItems caps a rotating image list.
+const (
+	MaxAlbumItems  = 10
+	MaxRotateItems = 20
+)
+
+// MediaItem is one file of a template's media.
+type MediaItem struct {
+	Type   string
+	FileID string
+}
+
+// Media returns the template's files. For album and rotate, MediaFileID
+// holds one "type:file_id" per line.
+func (t Template) Media() []MediaItem {
+	switch t.MediaType {
+	case "":
+		return nil
+	case MediaAlbum, MediaRotate:
+		return ParseMediaList(t.MediaFileID)
+	}
+	if t.MediaFileID == "" {
+		return nil
+	}
+	return []MediaItem{{Type: t.MediaType, FileID: t.MediaFileID}}
+}
+
+// ParseMediaList decodes an album/rotate media_file_id.
+func ParseMediaList(s string) []MediaItem {
+	var out []MediaItem
+	for _, line := range strings.Split(s, "\n") {
+		typ, id, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && id != "" {
+			out = append(out, MediaItem{Type: typ, FileID: id})
+		}
+	}
+	return out
+}
+
+// EncodeMediaList is the inverse of ParseMediaList.
+func EncodeMediaList(items []MediaItem) string {
+	lines := make([]string, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, it.Type+":"+it.FileID)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateMedia checks a media type and file list before it is stored.
+// Albums take 2–10 photos/videos or 2–10 documents; a rotation takes
+// 2–20 photos.
+func ValidateMedia(mediaType string, items []MediaItem) error {
+	switch mediaType {
+	case MediaPhoto, MediaVideo, MediaAnimation, MediaDocument:
+		if len(items) != 1 || items[0].Type != mediaType {
+			return fmt.Errorf("%s media needs exactly one %s file", mediaType, mediaType)
+		}
+	case MediaAlbum:
+		if len(items) < 2 || len(items) > MaxAlbumItems {
+			return fmt.Errorf("an album needs 2–%d files, got %d", MaxAlbumItems, len(items))
+		}
+		docs := 0
+		for _, it := range items {
+			switch it.Type {
+			case MediaPhoto, MediaVideo:
+			case MediaDocument:
+				docs++
+			default:
+				return fmt.Errorf("%s files can't be part of an album", it.Type)
+			}
+		}
+		if docs > 0 && docs != len(items) {
+			return errors.New("an album can't mix documents with photos or videos")
+		}
+	case MediaRotate:
+		if len(items) < 2 || len(items) > MaxRotateItems {
+			return fmt.Errorf("a rotation needs 2–%d images, got %d", MaxRotateItems, len(items))
+		}
+		for _, it := range items {
+			if it.Type != MediaPhoto {
+				return fmt.Errorf("a rotation takes photos only, got %s", it.Type)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown media type %q", mediaType)
+	}
+	for _, it := range items {
+		if it.FileID == "" || strings.ContainsAny(it.FileID, ":\n") {
+			return fmt.Errorf("invalid file id %q", it.FileID)
+		}
+	}
+	return nil
+}
+
+// SetTemplateMediaList validates and stores a template's media. One file
+// is stored as is; album/rotate lists are encoded into media_file_id.
+func (d *DB) SetTemplateMediaList(ctx context.Context, templateID, mediaType string, items []MediaItem) error {
+	if err := ValidateMedia(mediaType, items); err != nil {
+		return err
+	}
+	fileID := items[0].FileID
+	if mediaType == MediaAlbum || mediaType == MediaRotate {
+		fileID = EncodeMediaList(items)
+	}
+	return d.SetTemplateMedia(ctx, templateID, mediaType, fileID)
+}
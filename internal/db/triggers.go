@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// TriggerThreshold is how much an item must move before a trigger fires.
+// Type is "abs" (in the item's unit) or "pct".
+type TriggerThreshold struct {
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
+}
+
+// ThresholdFor returns the threshold configured for itemID, falling back to
+// the chat-wide trigger_threshold_type/value.
+func (s ChatSettings) ThresholdFor(itemID string) TriggerThreshold {
+	if th, ok := s.ItemThresholds[itemID]; ok {
+		return th
+	}
+	return TriggerThreshold{Type: s.TriggerThresholdType, Value: s.TriggerThresholdValue}
+}
+
+func (d *DB) getItemThresholds(ctx context.Context, chatID int64) (map[string]TriggerThreshold, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT item_id,threshold_type,threshold_value FROM chat_trigger_thresholds WHERE chat_id=?`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]TriggerThreshold{}
+	for rows.Next() {
+		var id string
+		var th TriggerThreshold
+		if err := rows.Scan(&id, &th.Type, &th.Value); err != nil {
+			return nil, err
+		}
+		out[id] = th
+	}
+	return out, rows.Err()
+}
+
+// SetItemThreshold overrides the trigger threshold for one item of a chat.
+func (d *DB) SetItemThreshold(ctx context.Context, chatID int64, itemID string, th TriggerThreshold) error {
+	old := d.column(ctx, `SELECT threshold_type||' '||threshold_value FROM chat_trigger_thresholds WHERE chat_id=? AND item_id=?`, chatID, itemID)
+	_, err := d.sql.ExecContext(ctx,
+		`INSERT INTO chat_trigger_thresholds(chat_id,item_id,threshold_type,threshold_value) VALUES(?,?,?,?)
+		 ON CONFLICT(chat_id,item_id) DO UPDATE SET threshold_type=excluded.threshold_type, threshold_value=excluded.threshold_value`,
+		chatID, itemID, th.Type, th.Value)
+	if err == nil {
+		d.record(ctx, chatID, "threshold:"+itemID, old, fmt.Sprintf("%s %v", th.Type, th.Value))
+	}
+	return err
+}
+
+// ClearItemThreshold removes the per-item override so the chat default applies again.
+func (d *DB) ClearItemThreshold(ctx context.Context, chatID int64, itemID string) error {
+	res, err := d.sql.ExecContext(ctx, `DELETE FROM chat_trigger_thresholds WHERE chat_id=? AND item_id=?`, chatID, itemID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n > 0 {
+			d.record(ctx, chatID, "threshold:"+itemID, "override", "")
+		}
+	}
+	return err
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+)
+
+// Limits for personal (non-admin) features.
+const (
+	MaxUserAlerts   = 5
+	MaxUserSubItems = 15
+	// MaxFiredUserAlerts is how many fired alerts a user keeps for
+	// reference; older ones are deleted.
+	MaxFiredUserAlerts = 5
+)
+
+// UserSubscription is a private daily board for one user.
+type UserSubscription struct {
+	UserID      int64
+	Enabled     bool
+	AtTime      string   // HH:MM Tehran
+	Items       []string // item IDs in order
+	LastSentKey string   // Jalali date of the last board sent
+}
+
+// UserAlert is a one-shot personal price alert ("USD above 70,000").
+type UserAlert struct {
+	AlertID   int64
+	UserID    int64
+	ItemID    string
+	Kind      string // above/below
+	Level     float64
+	Enabled   bool
+	CreatedAt int64
+}
+
+// GetUserSubscription returns the user's subscription, or the defaults if none was saved.
+func (d *DB) GetUserSubscription(ctx context.Context, userID int64) (UserSubscription, error) {
+	sub := UserSubscription{UserID: userID, AtTime: "09:00", Items: items.Defaults()}
+	var enabled int
+	var itemsJSON string
+	err := d.sql.QueryRowContext(ctx, `SELECT enabled,at_time,items,last_sent_key FROM user_subscriptions WHERE user_id=?`, userID).
+		Scan(&enabled, &sub.AtTime, &itemsJSON, &sub.LastSentKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sub, nil
+	}
+	if err != nil {
+		return UserSubscription{}, err
+	}
+	sub.Enabled = enabled == 1
+	_ = json.Unmarshal([]byte(itemsJSON), &sub.Items)
+	return sub, nil
+}
+
+// ListEnabledUserSubscriptions returns every enabled subscription (for the scheduler).
+func (d *DB) ListEnabledUserSubscriptions(ctx context.Context) ([]UserSubscription, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT user_id,at_time,items,last_sent_key FROM user_subscriptions WHERE enabled=1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserSubscription
+	for rows.Next() {
+		sub := UserSubscription{Enabled: true}
+		var itemsJSON string
+		if err := rows.Scan(&sub.UserID, &sub.AtTime, &itemsJSON, &sub.LastSentKey); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(itemsJSON), &sub.Items)
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+// UpdateUserSubscription sets one field of a user's subscription, creating it
+// (with default items) if needed.
+func (d *DB) UpdateUserSubscription(ctx context.Context, userID int64, key string, value any) error {
+	allowed := map[string]bool{"enabled": true, "at_time": true, "items": true, "last_sent_key": true}
+	if !allowed[key] {
+		return fmt.Errorf("invalid subscription key: %s", key)
+	}
+	switch v := value.(type) {
+	case bool:
+		value = boolInt(v)
+	case []string:
+		if len(v) > MaxUserSubItems {
+			return fmt.Errorf("at most %d items", MaxUserSubItems)
+		}
+		b, _ := json.Marshal(v)
+		value = string(b)
+	}
+	defaults, _ := json.Marshal(items.Defaults())
+	if _, err := d.sql.ExecContext(ctx, `INSERT OR IGNORE INTO user_subscriptions(user_id,items,created_at) VALUES(?,?,?)`,
+		userID, string(defaults), time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := d.sql.ExecContext(ctx, fmt.Sprintf(`UPDATE user_subscriptions SET %s=? WHERE user_id=?`, key), value, userID)
+	return err
+}
+
+// ListUserAlerts returns userID's alerts, active ones first. The list is
+// bounded by MaxUserAlerts active plus MaxFiredUserAlerts fired alerts.
+func (d *DB) ListUserAlerts(ctx context.Context, userID int64) ([]UserAlert, error) {
+	return d.queryUserAlerts(ctx, `WHERE user_id=? ORDER BY enabled DESC, alert_id DESC LIMIT ?`,
+		userID, MaxUserAlerts+MaxFiredUserAlerts)
+}
+
+func (d *DB) ListEnabledUserAlerts(ctx context.Context) ([]UserAlert, error) {
+	return d.queryUserAlerts(ctx, `WHERE enabled=1 ORDER BY alert_id`)
+}
+
+func (d *DB) queryUserAlerts(ctx context.Context, where string, args ...any) ([]UserAlert, error) {
+	rows, err := d.sql.QueryContext(ctx, `SELECT alert_id,user_id,item_id,kind,level,enabled,created_at FROM user_alerts `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserAlert
+	for rows.Next() {
+		var a UserAlert
+		var enabled int
+		if err := rows.Scan(&a.AlertID, &a.UserID, &a.ItemID, &a.Kind, &a.Level, &enabled, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Enabled = enabled == 1
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// CreateUserAlert adds an alert unless the user already has MaxUserAlerts active ones.
+func (d *DB) CreateUserAlert(ctx context.Context, a UserAlert) (int64, error) {
+	var n int
+	if err := d.sql.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_alerts WHERE user_id=? AND enabled=1`, a.UserID).Scan(&n); err != nil {
+		return 0, err
+	}
+	if n >= MaxUserAlerts {
+		return 0, fmt.Errorf("at most %d active alerts", MaxUserAlerts)
+	}
+	res, err := d.sql.ExecContext(ctx, `INSERT INTO user_alerts(user_id,item_id,kind,level,enabled,created_at) VALUES(?,?,?,?,1,?)`,
+		a.UserID, a.ItemID, a.Kind, a.Level, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// DeleteUserAlert deletes one of userID's alerts.
+func (d *DB) DeleteUserAlert(ctx context.Context, userID, alertID int64) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM user_alerts WHERE user_id=? AND alert_id=?`, userID, alertID)
+	return err
+}
+
+// DisableUserAlert turns an alert off after it fired and drops the user's
+// fired alerts beyond MaxFiredUserAlerts.
+func (d *DB) DisableUserAlert(ctx context.Context, alertID int64) error {
+	return d.inTx(ctx, func(tx *DB) error {
+		var userID int64
+		err := tx.sql.QueryRowContext(ctx, `SELECT user_id FROM user_alerts WHERE alert_id=?`, alertID).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.sql.ExecContext(ctx, `UPDATE user_alerts SET enabled=0 WHERE alert_id=?`, alertID); err != nil {
+			return err
+		}
+		return tx.pruneFiredUserAlerts(ctx, userID)
+	})
+}
+
+func (d *DB) pruneFiredUserAlerts(ctx context.Context, userID int64) error {
+	_, err := d.sql.ExecContext(ctx, `DELETE FROM user_alerts WHERE user_id=? AND enabled=0 AND alert_id NOT IN (
+		SELECT alert_id FROM user_alerts WHERE user_id=? AND enabled=0 ORDER BY alert_id DESC LIMIT ?)`,
+		userID, userID, MaxFiredUserAlerts)
+	return err
+}
+
+// DisableUserDelivery turns off a user's subscription and alerts, e.g. after
+// they blocked the bot.
+func (d *DB) DisableUserDelivery(ctx context.Context, userID int64) error {
+	return d.inTx(ctx, func(tx *DB) error {
+		if _, err := tx.sql.ExecContext(ctx, `UPDATE user_subscriptions SET enabled=0 WHERE user_id=?`, userID); err != nil {
+			return err
+		}
+		if _, err := tx.sql.ExecContext(ctx, `UPDATE user_alerts SET enabled=0 WHERE user_id=?`, userID); err != nil {
+			return err
+		}
+		return tx.pruneFiredUserAlerts(ctx, userID)
+	})
+}
+
+// DefaultSource returns the global provider/method used outside chat
+// settings (inline queries, conversions, personal boards and alerts).
+func (d *DB) DefaultSource(ctx context.Context) (provider, method string) {
+	provider, _, _ = d.GetGlobalSetting(ctx, "default_source_provider")
+	method, _, _ = d.GetGlobalSetting(ctx, "default_source_method")
+	if provider == "" {
+		provider = "bonbast"
+	}
+	if method == "" {
+		method = "scrape"
+	}
+	return provider, method
+}
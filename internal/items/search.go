@@ -0,0 +1,79 @@
+package items
+
+import (
+	"sort"
+	"strings"
+)
+
+// NormalizeName folds the spelling variants users type for Persian names:
+// Arabic yeh/kaf, zero-width non-joiners and case.
+func NormalizeName(s string) string {
+	r := strings.NewReplacer("ي", "ی", "ك", "ک", "‌", " ", "ـ", "")
+	return strings.ToLower(strings.Join(strings.Fields(r.Replace(s)), " "))
+}
+
+// Search returns the items matching q by ID or Persian name, best match
+// first: exact ID/name, then prefix, then substring, then an ID one typo away.
+func Search(q string) []Item {
+	q = NormalizeName(q)
+	if q == "" {
+		return nil
+	}
+	type hit struct {
+		it    Item
+		score int
+		pos   int
+	}
+	var hits []hit
+	for i, it := range All {
+		id := strings.ToLower(it.ID)
+		name := NormalizeName(it.NameFa)
+		score := -1
+		switch {
+		case id == q || name == q:
+			score = 0
+		case strings.HasPrefix(id, q) || strings.HasPrefix(name, q):
+			score = 1
+		case strings.Contains(name, q):
+			score = 2
+		case len(q) >= 3 && editDistance(id, q) <= 1:
+			score = 3
+		}
+		if score >= 0 {
+			hits = append(hits, hit{it, score, i})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score < hits[j].score
+		}
+		return hits[i].pos < hits[j].pos
+	})
+	out := make([]Item, len(hits))
+	for i, h := range hits {
+		out[i] = h.it
+	}
+	return out
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
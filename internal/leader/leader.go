@@ -0,0 +1,144 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+const (
+	leaseName = "main"
+	leaseTTL  = 30 * time.Second
+	heartbeat = 10 * time.Second
+)
+
+// Elector keeps a lease row in the database so that only one running copy of
+// the bot polls updates and posts. Other copies stay in standby and take over
+// once the lease expires.
+type Elector struct {
+	mu       sync.Mutex
+	db       *db.DB
+	leader   bool
+	renewed  time.Time
+	holder   string
+	onChange func(leader bool, prev db.Lease)
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates an elector. onChange is called (from the elector goroutine)
+// whenever this instance gains or loses leadership; prev is the lease row
+// that was replaced when leadership was gained.
+func New(database *db.DB, onChange func(leader bool, prev db.Lease)) *Elector {
+	host, _ := os.Hostname()
+	return &Elector{
+		db:       database,
+		holder:   fmt.Sprintf("%s/%d/%s", host, os.Getpid(), strings.ReplaceAll(uuid.New().String(), "-", "")[:8]),
+		onChange: onChange,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Holder identifies this instance in the lease row.
+func (e *Elector) Holder() string { return e.holder }
+
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// SetDB swaps the database (used after a restore).
+func (e *Elector) SetDB(database *db.DB) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.db = database
+}
+
+func (e *Elector) Start() {
+	e.tryAcquire()
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		t := time.NewTicker(heartbeat)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				e.tryAcquire()
+			case <-e.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the heartbeat and releases the lease so a standby can take over immediately.
+func (e *Elector) Stop() {
+	close(e.stopCh)
+	e.wg.Wait()
+
+	e.mu.Lock()
+	database := e.db
+	wasLeader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+	if wasLeader {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = database.ReleaseLease(ctx, leaseName, e.holder)
+	}
+}
+
+func (e *Elector) tryAcquire() {
+	e.mu.Lock()
+	database := e.db
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ok, prev, err := database.AcquireLease(ctx, leaseName, e.holder, leaseTTL)
+
+	e.mu.Lock()
+	was := e.leader
+	switch {
+	case err != nil:
+		log.Printf("[leader] heartbeat: %v", err)
+		// Keep leadership only while the last successful renewal is still valid.
+		if e.leader && time.Since(e.renewed) >= leaseTTL {
+			e.leader = false
+		}
+	case ok:
+		e.leader = true
+		e.renewed = time.Now()
+	default:
+		e.leader = false
+	}
+	now := e.leader
+	e.mu.Unlock()
+
+	if now == was {
+		return
+	}
+	if now {
+		if prev.Holder != "" && prev.Holder != e.holder {
+			log.Printf("[leader] %s took over the lease from %s (expired %s)", e.holder, prev.Holder, time.Unix(prev.ExpiresAt, 0).Format(time.RFC3339))
+		} else {
+			log.Printf("[leader] %s acquired the lease", e.holder)
+		}
+	} else {
+		log.Printf("[leader] %s lost the lease (held by %s); entering standby", e.holder, prev.Holder)
+	}
+	if e.onChange != nil {
+		e.onChange(now, prev)
+	}
+}
@@ -0,0 +1,285 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram flood limits (see https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this).
+const (
+	globalGap      = time.Second / 30 // ~30 messages per second overall
+	privateChatGap = time.Second      // ~1 message per second in a private chat
+	groupChatGap   = 3 * time.Second  // ~20 messages per minute in a group/channel
+
+	maxAttempts = 4
+	inFlight    = 8   // concurrent API calls across all chats
+	laneSize    = 256 // queued jobs per chat
+)
+
+// DropStats describes sends to one chat that were given up on.
+type DropStats struct {
+	Count     int
+	LastError string
+	LastAt    time.Time
+}
+
+type job struct {
+	ctx    context.Context
+	chatID int64
+	c      tgbotapi.Chattable
+	res    chan result
+}
+
+type result struct {
+	resp *tgbotapi.APIResponse
+	err  error
+}
+
+// lane is the queue of one chat. It is drained by its own goroutine, so a
+// chat waiting out its rate limit never holds up other chats.
+type lane struct {
+	jobs    []*job
+	running bool
+}
+
+// Outbox is the central outbound queue for background Telegram calls
+// (scheduled posts, admin notifications). It paces requests to stay under
+// the global and per-chat limits, honors retry_after on 429 and retries
+// transient failures. Sends that still fail are recorded as dropped.
+type Outbox struct {
+	bot *tgbotapi.BotAPI
+
+	sem    chan struct{}
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	mu         sync.Mutex
+	closed     bool
+	lanes      map[int64]*lane
+	nextGlobal time.Time
+	nextChat   map[int64]time.Time
+	drops      map[int64]DropStats
+}
+
+func New(bot *tgbotapi.BotAPI) *Outbox {
+	return &Outbox{
+		bot:      bot,
+		sem:      make(chan struct{}, inFlight),
+		stopCh:   make(chan struct{}),
+		lanes:    map[int64]*lane{},
+		nextChat: map[int64]time.Time{},
+		drops:    map[int64]DropStats{},
+	}
+}
+
+// Close stops the lanes. Queued jobs that were not started fail with an error.
+func (o *Outbox) Close() {
+	o.once.Do(func() {
+		o.mu.Lock()
+		o.closed = true
+		o.mu.Unlock()
+		close(o.stopCh)
+		o.wg.Wait()
+	})
+}
+
+// Request enqueues c for chatID and waits for the API response.
+func (o *Outbox) Request(ctx context.Context, chatID int64, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	j := &job{ctx: ctx, chatID: chatID, c: c, res: make(chan result, 1)}
+	if err := o.enqueue(j); err != nil {
+		o.recordDrop(chatID, err)
+		return nil, err
+	}
+	select {
+	case r := <-j.res:
+		return r.resp, r.err
+	case <-ctx.Done():
+		// drain skips the job once it reaches the head of the lane.
+		return nil, ctx.Err()
+	case <-o.stopCh:
+		return nil, errors.New("outbox closed")
+	}
+}
+
+// enqueue adds j to its chat's lane and starts the lane if it is idle.
+func (o *Outbox) enqueue(j *job) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return errors.New("outbox closed")
+	}
+	l := o.lanes[j.chatID]
+	if l == nil {
+		l = &lane{}
+		o.lanes[j.chatID] = l
+	}
+	if len(l.jobs) >= laneSize {
+		return errors.New("outbox: chat queue full")
+	}
+	l.jobs = append(l.jobs, j)
+	if !l.running {
+		l.running = true
+		o.wg.Add(1)
+		go o.drain(j.chatID, l)
+	}
+	return nil
+}
+
+// Send is like Request but decodes the resulting message.
+func (o *Outbox) Send(ctx context.Context, chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	resp, err := o.Request(ctx, chatID, c)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	var msg tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &msg)
+	return msg, err
+}
+
+// Drops returns the dropped-send stats for chatID.
+func (o *Outbox) Drops(chatID int64) DropStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.drops[chatID]
+}
+
+// drain runs one chat's jobs in order and exits when the lane is empty.
+func (o *Outbox) drain(chatID int64, l *lane) {
+	defer o.wg.Done()
+	for {
+		o.mu.Lock()
+		if len(l.jobs) == 0 {
+			l.running = false
+			delete(o.lanes, chatID)
+			o.mu.Unlock()
+			return
+		}
+		j := l.jobs[0]
+		l.jobs = l.jobs[1:]
+		o.mu.Unlock()
+
+		if err := j.ctx.Err(); err != nil {
+			// The caller gave up while the job was queued.
+			o.recordDrop(j.chatID, err)
+			j.res <- result{err: err}
+			continue
+		}
+		resp, err := o.do(j)
+		if err != nil && !isNotModified(err) {
+			o.recordDrop(j.chatID, err)
+		}
+		j.res <- result{resp: resp, err: err}
+	}
+}
+
+func (o *Outbox) do(j *job) (*tgbotapi.APIResponse, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := o.wait(j.ctx, j.chatID); err != nil {
+			return nil, err
+		}
+		o.sem <- struct{}{}
+		resp, err := o.bot.Request(j.c)
+		<-o.sem
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) {
+			if tgErr.RetryAfter > 0 {
+				o.pauseChat(j.chatID, time.Duration(tgErr.RetryAfter)*time.Second)
+				log.Printf("[outbox] chat %d: flood control, retry after %ds", j.chatID, tgErr.RetryAfter)
+				continue
+			}
+			if tgErr.Code < 500 {
+				// Permanent (bad request, forbidden, ...): retrying won't help.
+				return resp, err
+			}
+		}
+		// Network error or Telegram 5xx: back off and retry.
+		o.pauseChat(j.chatID, time.Duration(1<<(attempt-1))*time.Second)
+	}
+	return nil, lastErr
+}
+
+// wait blocks until both the chat and the global rate allow another request.
+func (o *Outbox) wait(ctx context.Context, chatID int64) error {
+	gap := groupChatGap
+	if chatID > 0 {
+		gap = privateChatGap
+	}
+
+	o.mu.Lock()
+	now := time.Now()
+	slot := o.nextChat[chatID]
+	if slot.Before(now) {
+		slot = now
+	}
+	o.nextChat[chatID] = slot.Add(gap)
+	o.mu.Unlock()
+	if err := sleepUntil(ctx, o.stopCh, slot); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	now = time.Now()
+	slot = o.nextGlobal
+	if slot.Before(now) {
+		slot = now
+	}
+	o.nextGlobal = slot.Add(globalGap)
+	o.mu.Unlock()
+	return sleepUntil(ctx, o.stopCh, slot)
+}
+
+func (o *Outbox) pauseChat(chatID int64, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	until := time.Now().Add(d)
+	if o.nextChat[chatID].Before(until) {
+		o.nextChat[chatID] = until
+	}
+}
+
+func (o *Outbox) recordDrop(chatID int64, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	st := o.drops[chatID]
+	st.Count++
+	st.LastError = err.Error()
+	st.LastAt = time.Now()
+	o.drops[chatID] = st
+}
+
+// isNotModified reports an edit that changed nothing; it is not a failed send.
+func isNotModified(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && strings.Contains(tgErr.Message, "message is not modified")
+}
+
+func sleepUntil(ctx context.Context, stopCh <-chan struct{}, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-stopCh:
+		return errors.New("outbox closed")
+	}
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// Alert template placeholders: {EMOJI} {NAME} {PRICE} {LEVEL} {CHANGE} {WINDOW} {DATETIME}
+var defaultAlertTemplates = map[string]string{
+	"above": "🚨 {EMOJI} {NAME} از {LEVEL} عبور کرد ⬆️\n💰 قیمت فعلی: {PRICE}\n🕒 {DATETIME}",
+	"below": "🚨 {EMOJI} {NAME} به زیر {LEVEL} رسید ⬇️\n💰 قیمت فعلی: {PRICE}\n🕒 {DATETIME}",
+	"move":  "🚨 {EMOJI} {NAME} در {WINDOW} دقیقه {CHANGE} تغییر کرد\n💰 قیمت فعلی: {PRICE}\n🕒 {DATETIME}",
+}
+
+// DefaultAlertTemplate returns the built-in text for an alert kind.
+func DefaultAlertTemplate(kind string) string {
+	return defaultAlertTemplates[kind]
+}
+
+// AlertText renders an alert rule that fired. changePct is the move in
+// percent (only meaningful for "move" rules).
+func AlertText(rule db.AlertRule, it items.Item, unit, digits string, price, changePct float64) string {
+	body := rule.Template
+	if strings.TrimSpace(body) == "" {
+		body = DefaultAlertTemplate(rule.Kind)
+	}
+
+	level := utils.FormatNumber(rule.Level, unit, digits)
+	if rule.Kind == "move" {
+		level = strconv.FormatFloat(rule.Level, 'f', -1, 64) + "%"
+	}
+	change := fmt.Sprintf("%+.2f%%", changePct)
+	window := strconv.Itoa(rule.WindowMinutes)
+	dt := utils.JalaliDateTime(utils.NowTehran())
+	if digits == "fa" {
+		level = utils.ToPersianDigits(level)
+		change = utils.ToPersianDigits(change)
+		window = utils.ToPersianDigits(window)
+		dt = utils.ToPersianDigits(dt)
+	}
+
+	r := strings.NewReplacer(
+		"{EMOJI}", it.Emoji,
+		"{NAME}", it.NameFa,
+		"{PRICE}", utils.FormatNumber(price, unit, digits),
+		"{LEVEL}", level,
+		"{CHANGE}", change,
+		"{WINDOW}", window,
+		"{DATETIME}", dt,
+	)
+	return strings.TrimSpace(r.Replace(body))
+}
@@ -0,0 +1,87 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// Digest template placeholders: {PERIOD} {DATE} {FROM} {TO} {ITEMS}
+const DefaultDigestTemplate = "📊 خلاصه {PERIOD} بازار\n📅 {DATE}\n\n{ITEMS}"
+
+var digestPeriodNames = map[string]string{
+	"daily":   "روزانه",
+	"weekly":  "هفتگی",
+	"monthly": "ماهانه",
+}
+
+// DigestPeriodName returns the Persian name of a digest period.
+func DigestPeriodName(period string) string {
+	if n, ok := digestPeriodNames[period]; ok {
+		return n
+	}
+	return period
+}
+
+// OHLC is the open/high/low/close of one item over a digest period.
+type OHLC struct {
+	Item                   items.Item
+	Unit                   string
+	Open, High, Low, Close float64
+}
+
+// ChangePct is the move from open to close in percent.
+func (o OHLC) ChangePct() float64 {
+	if o.Open == 0 {
+		return 0
+	}
+	return (o.Close - o.Open) / o.Open * 100
+}
+
+// DigestText renders a digest post. from/to are Jalali dates of the period.
+func DigestText(tmpl, period, from, to, digits string, rows []OHLC) string {
+	if strings.TrimSpace(tmpl) == "" {
+		tmpl = DefaultDigestTemplate
+	}
+
+	var b strings.Builder
+	for i, o := range rows {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		pct := o.ChangePct()
+		arrow := "▬"
+		if pct > 0 {
+			arrow = "▲"
+		} else if pct < 0 {
+			arrow = "🔻"
+		}
+		change := fmt.Sprintf("%+.2f%%", pct)
+		if digits == "fa" {
+			change = utils.ToPersianDigits(change)
+		}
+		fmt.Fprintf(&b, "%s %s %s %s\n", o.Item.Emoji, o.Item.NameFa, arrow, change)
+		fmt.Fprintf(&b, "باز: %s | بسته: %s\n", utils.FormatNumber(o.Open, o.Unit, digits), utils.FormatNumber(o.Close, o.Unit, digits))
+		fmt.Fprintf(&b, "بیشترین: %s | کمترین: %s", utils.FormatNumber(o.High, o.Unit, digits), utils.FormatNumber(o.Low, o.Unit, digits))
+	}
+
+	date := to
+	if from != to {
+		date = from + " تا " + to
+	}
+	if digits == "fa" {
+		date = utils.ToPersianDigits(date)
+		from = utils.ToPersianDigits(from)
+		to = utils.ToPersianDigits(to)
+	}
+	r := strings.NewReplacer(
+		"{PERIOD}", DigestPeriodName(period),
+		"{DATE}", date,
+		"{FROM}", from,
+		"{TO}", to,
+		"{ITEMS}", b.String(),
+	)
+	return strings.TrimSpace(r.Replace(tmpl))
+}
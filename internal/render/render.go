@@ -3,6 +3,8 @@ package render
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 
 	"github.com/Armin-kho/persian-currency-bot/internal/db"
@@ -29,6 +31,14 @@ type Output struct {
 	UsedValues map[string]float64
 	MediaType string
 	MediaFileID string
+	// Media lists the files of an album, or the images of a rotating
+	// template (Rotating); MediaType/MediaFileID then hold the current image.
+	Media    []db.MediaItem
+	Rotating bool
+	// Hash identifies the rendered board; TimelessHash ignores the
+	// {DATETIME}/{DATE}/{TIME} placeholders. Used to skip no-op edits.
+	Hash         string
+	TimelessHash string
 }
 
 // BuildMessage renders the current template into a final message text.
@@ -107,6 +117,8 @@ func BuildMessage(ctx context.Context, settings db.ChatSettings, tmpl db.Templat
 	body = strings.ReplaceAll(body, "{COINS}", strings.Join(coins, "\n"))
 	body = strings.ReplaceAll(body, "{GOLD}", strings.Join(golds, "\n"))
 
+	timeless := strings.NewReplacer("{DATETIME}", "", "{DATE}", "", "{TIME}", "").Replace(body)
+
 	dt := utils.JalaliDateTime(utils.NowTehran())
 	if settings.Digits == "fa" {
 		dt = utils.ToPersianDigits(dt)
@@ -120,21 +132,45 @@ func BuildMessage(ctx context.Context, settings db.ChatSettings, tmpl db.Templat
 	// We can trim extra blank lines.
 	body = strings.TrimSpace(body)
 
-	return Output{
+	out := Output{
 		Text:        body,
 		Lines:       lines,
 		UsedValues:  used,
 		MediaType:   tmpl.MediaType,
 		MediaFileID: tmpl.MediaFileID,
+		Hash:         outputHash(body, tmpl),
+		TimelessHash: outputHash(strings.TrimSpace(timeless), tmpl),
+	}
+	switch tmpl.MediaType {
+	case db.MediaAlbum:
+		out.Media = tmpl.Media()
+	case db.MediaRotate:
+		out.Media = tmpl.Media()
+		out.Rotating = true
+		out.RotateTo(settings.MediaIndex)
 	}
+	return out
 }
 
-func formatPrice(priceMode, digits string, q sources.Quote) (string, float64, bool) {
-	// pick usedVal for comparison
+// RotateTo shows image i (mod the list length) of a rotating template.
+func (o *Output) RotateTo(i int) {
+	if !o.Rotating || len(o.Media) == 0 {
+		return
+	}
+	n := len(o.Media)
+	m := o.Media[((i%n)+n)%n]
+	o.MediaType, o.MediaFileID = m.Type, m.FileID
+}
+
+func outputHash(text string, tmpl db.Template) string {
+	sum := sha256.Sum256([]byte(tmpl.MediaType + "\x00" + tmpl.MediaFileID + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// UsedValue picks the value compared for arrows/triggers according to price_mode.
+func UsedValue(priceMode string, q sources.Quote) (float64, bool) {
 	var usedVal float64
 	var hasVal bool
-	var unit string = q.Unit
-
 	switch priceMode {
 	case "buy":
 		if q.Buy != nil {
@@ -163,6 +199,12 @@ func formatPrice(priceMode, digits string, q sources.Quote) (string, float64, bo
 		}
 	}
 
+	return usedVal, hasVal
+}
+
+func formatPrice(priceMode, digits string, q sources.Quote) (string, float64, bool) {
+	unit := q.Unit
+	usedVal, hasVal := UsedValue(priceMode, q)
 	if !hasVal {
 		return "", 0, false
 	}
@@ -185,3 +227,17 @@ func formatPrice(priceMode, digits string, q sources.Quote) (string, float64, bo
 		return utils.FormatNumber(usedVal, unit, digits), usedVal, true
 	}
 }
+
+// QuoteList renders only the given items, one line each in the given order,
+// followed by the date/time. It returns "" if none of them has a price.
+func QuoteList(ctx context.Context, settings db.ChatSettings, itemIDs []string, snap sources.Snapshot, lastValues map[string]float64) string {
+	out := BuildMessage(ctx, settings, db.Template{Body: "{DATETIME}"}, itemIDs, snap, lastValues)
+	if len(out.Lines) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(out.Lines))
+	for _, ln := range out.Lines {
+		lines = append(lines, ln.Text)
+	}
+	return strings.Join(lines, "\n") + "\n\n🕒 " + out.Text
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+)
+
+// evaluateAlerts checks every enabled alert rule of the active chats against
+// the latest snapshot and posts a separate message for rules that fire.
+func (s *Scheduler) evaluateAlerts(ctx context.Context, active map[int64]db.ChatSettings, minuteOfDay int) {
+	rules, err := s.db.ListEnabledAlertRules(ctx)
+	if err != nil {
+		log.Printf("[scheduler] list alert rules: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, r := range rules {
+		settings, ok := active[r.ChatID]
+		if !ok || inDowntime(settings, minuteOfDay) {
+			continue
+		}
+		it, ok := items.ByID(r.ItemID)
+		if !ok {
+			continue
+		}
+		snap, err := s.src.Get(ctx, sources.Provider(settings.SourceProvider), sources.Method(settings.SourceMethod))
+		if err != nil {
+			continue
+		}
+		s.recordSnapshot(ctx, snap)
+		q, ok := snap.Quotes[r.ItemID]
+		if !ok {
+			continue
+		}
+		cur, ok := render.UsedValue(settings.PriceMode, q)
+		if !ok {
+			continue
+		}
+
+		fire := false
+		changePct := 0.0
+		switch r.Kind {
+		case "above":
+			fire = r.LastValue.Valid && r.LastValue.Float64 < r.Level && cur >= r.Level
+		case "below":
+			fire = r.LastValue.Valid && r.LastValue.Float64 > r.Level && cur <= r.Level
+		case "move":
+			pts, err := s.db.PricesAt(ctx, settings.SourceProvider, settings.SourceMethod, []string{r.ItemID}, now.Add(-time.Duration(r.WindowMinutes)*time.Minute))
+			if err != nil {
+				break
+			}
+			if p, ok := pts[r.ItemID]; ok {
+				if old, ok := render.UsedValue(settings.PriceMode, sources.Quote{Sell: p.Sell, Buy: p.Buy}); ok && old != 0 {
+					changePct = (cur - old) / old * 100
+					fire = r.Level > 0 && math.Abs(changePct) >= r.Level
+				}
+			}
+		}
+		if fire && r.LastFiredAt.Valid && now.Sub(time.Unix(r.LastFiredAt.Int64, 0)) < time.Duration(r.CooldownMinutes)*time.Minute {
+			fire = false
+		}
+		if !fire {
+			_ = s.db.SetAlertState(ctx, r.RuleID, cur, nil)
+			continue
+		}
+
+		text := render.AlertText(r, it, q.Unit, settings.Digits, cur, changePct)
+		if err := s.sendAlert(ctx, r, text); err != nil {
+			_ = s.db.SetLastError(ctx, r.ChatID, "alert: "+err.Error())
+			// Keep the old last_value so a crossing is retried next tick.
+			continue
+		}
+		_ = s.db.SetAlertState(ctx, r.RuleID, cur, &now)
+	}
+}
+
+func (s *Scheduler) sendAlert(ctx context.Context, r db.AlertRule, text string) error {
+	msg := tgbotapi.NewMessage(r.ChatID, text)
+	msg.DisableWebPagePreview = true
+	sent, err := s.out.Send(ctx, r.ChatID, msg)
+	if err != nil {
+		return err
+	}
+	_ = s.db.TrackMessage(ctx, r.ChatID, sent.MessageID, "alert")
+	if r.Pin {
+		pin := tgbotapi.PinChatMessageConfig{ChatID: r.ChatID, MessageID: sent.MessageID}
+		if _, err := s.out.Request(ctx, r.ChatID, pin); err != nil {
+			_ = s.db.SetLastError(ctx, r.ChatID, "alert pin: "+err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+var errNoDigestData = errors.New("no price data for this period")
+
+// digestKey identifies the period a digest covers, e.g. "weekly:1404/07/19".
+func digestKey(period string, now time.Time) string {
+	return period + ":" + utils.JalaliDate(utils.PeriodStart(now, period))
+}
+
+// sendDigests posts the digests of active chats whose time has come. A digest
+// goes out once per period, at or after its time on the period's last day.
+func (s *Scheduler) sendDigests(ctx context.Context, active map[int64]db.ChatSettings, now time.Time) {
+	digests, err := s.db.ListEnabledDigests(ctx)
+	if err != nil {
+		log.Printf("[scheduler] list digests: %v", err)
+		return
+	}
+	minuteOfDay := now.Hour()*60 + now.Minute()
+	for _, dg := range digests {
+		settings, ok := active[dg.ChatID]
+		if !ok || !utils.IsPeriodEnd(now, dg.Period) {
+			continue
+		}
+		at, ok := utils.ParseHHMM(dg.AtTime)
+		if !ok || minuteOfDay < at {
+			continue
+		}
+		key := digestKey(dg.Period, now)
+		if dg.LastSentKey == key {
+			continue
+		}
+		text, err := BuildDigest(ctx, s.db, s.src, settings, dg, now)
+		if err != nil {
+			log.Printf("[scheduler] chat %d: %s digest: %v", dg.ChatID, dg.Period, err)
+			continue
+		}
+		msg := tgbotapi.NewMessage(dg.ChatID, text)
+		msg.DisableWebPagePreview = true
+		sent, err := s.out.Send(ctx, dg.ChatID, msg)
+		if err != nil {
+			_ = s.db.SetLastError(ctx, dg.ChatID, "digest: "+err.Error())
+			continue
+		}
+		_ = s.db.TrackMessage(ctx, dg.ChatID, sent.MessageID, "digest")
+		_ = s.db.UpdateDigest(ctx, dg.ChatID, dg.Period, "last_sent_key", key)
+	}
+}
+
+// BuildDigest renders the digest of settings.ChatID for the period containing
+// now, from price history plus the current snapshot as the close.
+func BuildDigest(ctx context.Context, database *db.DB, src *sources.Manager, settings db.ChatSettings, dg db.Digest, now time.Time) (string, error) {
+	enabledIDs, err := database.EnabledItemIDs(ctx, settings.ChatID)
+	if err != nil {
+		return "", err
+	}
+	start := utils.PeriodStart(now, dg.Period)
+	hist, err := database.PricesBetween(ctx, settings.SourceProvider, settings.SourceMethod, enabledIDs, start, now)
+	if err != nil {
+		return "", err
+	}
+	snap, snapErr := src.Get(ctx, sources.Provider(settings.SourceProvider), sources.Method(settings.SourceMethod))
+
+	var rows []render.OHLC
+	for _, id := range enabledIDs {
+		it, ok := items.ByID(id)
+		if !ok {
+			continue
+		}
+		var vals []float64
+		for _, p := range hist[id] {
+			if v, ok := render.UsedValue(settings.PriceMode, sources.Quote{Sell: p.Sell, Buy: p.Buy}); ok {
+				vals = append(vals, v)
+			}
+		}
+		unit := ""
+		if snapErr == nil {
+			if q, ok := snap.Quotes[id]; ok {
+				unit = q.Unit
+				if v, ok := render.UsedValue(settings.PriceMode, q); ok {
+					vals = append(vals, v)
+				}
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		o := render.OHLC{Item: it, Unit: unit, Open: vals[0], Close: vals[len(vals)-1], High: vals[0], Low: vals[0]}
+		for _, v := range vals {
+			if v > o.High {
+				o.High = v
+			}
+			if v < o.Low {
+				o.Low = v
+			}
+		}
+		rows = append(rows, o)
+	}
+	if len(rows) == 0 {
+		if snapErr != nil {
+			return "", snapErr
+		}
+		return "", errNoDigestData
+	}
+	return render.DigestText(dg.Template, dg.Period, utils.JalaliDate(start), utils.JalaliDate(now), settings.Digits, rows), nil
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+const (
+	// historySampleEvery is how often every provider in use is sampled into
+	// price_history, independent of chat intervals.
+	historySampleEvery = 5
+	historyRetention   = 40 * 24 * time.Hour
+
+	// MinHistoryCooldownMinutes is the cooldown enforced for history
+	// baselines. Unlike last_post, a day_open or minutes_ago baseline does
+	// not move when the board is posted, so a crossed threshold would
+	// otherwise post on every tick.
+	MinHistoryCooldownMinutes = 30
+)
+
+// TriggerCooldown returns the cooldown in minutes that applies to settings.
+func TriggerCooldown(settings db.ChatSettings) int {
+	cd := settings.TriggerCooldownMinutes
+	if settings.TriggerBaseline != "" && settings.TriggerBaseline != "last_post" && cd < MinHistoryCooldownMinutes {
+		cd = MinHistoryCooldownMinutes
+	}
+	return cd
+}
+
+// recordSnapshot stores snap in price_history once per fetch.
+func (s *Scheduler) recordSnapshot(ctx context.Context, snap sources.Snapshot) {
+	key := string(snap.Provider) + "|" + string(snap.Method)
+	s.mu.Lock()
+	if s.lastRecorded[key].Equal(snap.FetchedAt) {
+		s.mu.Unlock()
+		return
+	}
+	s.lastRecorded[key] = snap.FetchedAt
+	s.mu.Unlock()
+
+	points := make([]db.PricePoint, 0, len(snap.Quotes))
+	for id, q := range snap.Quotes {
+		points = append(points, db.PricePoint{ItemID: id, Sell: q.Sell, Buy: q.Buy})
+	}
+	if err := s.db.RecordPrices(ctx, string(snap.Provider), string(snap.Method), snap.FetchedAt, points); err != nil {
+		log.Printf("[scheduler] record prices: %v", err)
+	}
+}
+
+// sampleHistory fetches every provider/method used by an active chat so
+// history has regular points even for chats with long intervals.
+func (s *Scheduler) sampleHistory(ctx context.Context, sourcesInUse map[[2]string]bool) {
+	for pm := range sourcesInUse {
+		snap, err := s.src.Get(ctx, sources.Provider(pm[0]), sources.Method(pm[1]))
+		if err != nil {
+			continue
+		}
+		s.recordSnapshot(ctx, snap)
+	}
+}
+
+// Baseline returns the values that triggers and arrows compare against for
+// settings.TriggerBaseline. Items without history fall back to the values
+// saved at the last post.
+func Baseline(ctx context.Context, database *db.DB, settings db.ChatSettings, itemIDs []string) (map[string]float64, error) {
+	lastVals, err := database.GetLastValues(ctx, settings.ChatID, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var points map[string]db.PricePoint
+	switch settings.TriggerBaseline {
+	case "day_open":
+		now := utils.NowTehran()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		points, err = database.FirstPricesSince(ctx, settings.SourceProvider, settings.SourceMethod, itemIDs, midnight)
+	case "minutes_ago":
+		mins := settings.TriggerBaselineMinutes
+		if mins <= 0 {
+			mins = 60
+		}
+		points, err = database.PricesAt(ctx, settings.SourceProvider, settings.SourceMethod, itemIDs, time.Now().Add(-time.Duration(mins)*time.Minute))
+	default: // last_post
+		return lastVals, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for id, p := range points {
+		if v, ok := render.UsedValue(settings.PriceMode, sources.Quote{Sell: p.Sell, Buy: p.Buy}); ok {
+			lastVals[id] = v
+		}
+	}
+	return lastVals, nil
+}
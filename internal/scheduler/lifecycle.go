@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleChatGone reacts to send errors that mean the chat itself changed:
+// a group upgraded to a supergroup is moved to its new ID, and a removed or
+// muted bot marks the chat with a problem so later ticks skip it.
+// It returns false if err is not such an error.
+func (s *Scheduler) handleChatGone(ctx context.Context, chatID int64, err error) bool {
+	if newID := migratedTo(err); newID != 0 {
+		moved, mErr := s.db.MigrateChat(ctx, chatID, newID)
+		if mErr != nil {
+			log.Printf("[scheduler] migrate chat %d -> %d: %v", chatID, newID, mErr)
+			return true
+		}
+		if moved && s.notify != nil {
+			kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("⚙️ تنظیمات", fmt.Sprintf("chat|%d", newID)),
+			))
+			s.notify.NotifyAdmins(ctx, fmt.Sprintf("🔀 گروه %d به سوپرگروه %d تبدیل شد؛ تنظیمات منتقل شد.", chatID, newID), &kb)
+		}
+		return true
+	}
+	problem := sendProblem(err)
+	if problem == "" {
+		return false
+	}
+	if ch, gErr := s.db.GetChat(ctx, chatID); gErr == nil && ch.Problem == problem {
+		return true
+	}
+	_ = s.db.SetChatProblem(ctx, chatID, problem)
+	if s.notify != nil {
+		kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚠️ چت‌های مشکل‌دار", "probchats"),
+		))
+		s.notify.NotifyAdmins(ctx, fmt.Sprintf("⚠️ ارسال به چت %d ممکن نیست (%s). ارسال‌های زمان‌بندی‌شده این چت متوقف شد.", chatID, problem), &kb)
+	}
+	return true
+}
+
+// expirePendingChats denies and leaves chats that waited for approval longer
+// than the approval policy allows.
+func (s *Scheduler) expirePendingChats(ctx context.Context, now time.Time) {
+	pol, err := s.db.GetApprovalPolicy(ctx)
+	if err != nil || pol.ExpiryHours <= 0 {
+		return
+	}
+	chats, err := s.db.ListPendingBefore(ctx, now.Add(-time.Duration(pol.ExpiryHours)*time.Hour))
+	if err != nil {
+		log.Printf("[scheduler] list pending chats: %v", err)
+		return
+	}
+	for _, c := range chats {
+		_ = s.db.SetChatApproved(ctx, c.ChatID, false)
+		_ = s.db.SetChatEnabled(ctx, c.ChatID, false)
+		_, _ = s.out.Send(ctx, c.ChatID, tgbotapi.NewMessage(c.ChatID, "⌛️ درخواست تایید این چت منقضی شد و ربات خارج می‌شود."))
+		_, _ = s.out.Request(ctx, c.ChatID, tgbotapi.LeaveChatConfig{ChatID: c.ChatID})
+		if s.notify != nil {
+			s.notify.NotifyAdmins(ctx, fmt.Sprintf("⌛️ درخواست تایید «%s» (%d) پس از %d ساعت منقضی شد؛ ربات از چت خارج شد.", c.Title, c.ChatID, pol.ExpiryHours), nil)
+		}
+	}
+}
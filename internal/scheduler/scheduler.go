@@ -3,6 +3,7 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
@@ -11,11 +12,22 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/outbox"
 	"github.com/Armin-kho/persian-currency-bot/internal/render"
 	"github.com/Armin-kho/persian-currency-bot/internal/sources"
 	"github.com/Armin-kho/persian-currency-bot/internal/utils"
 )
 
+// postRetryDelay is how soon a failed scheduled post is tried again.
+const postRetryDelay = 2 * time.Minute
+
+// postTimeout bounds one scheduled post (fetch, render, send with outbox
+// retries); tickJobsTimeout bounds the alert/digest/history jobs after it.
+const (
+	postTimeout     = 40 * time.Second
+	tickJobsTimeout = 50 * time.Second
+)
+
 type Notifier interface {
 	NotifyAdmins(ctx context.Context, text string, kb *tgbotapi.InlineKeyboardMarkup)
 }
@@ -23,28 +35,58 @@ type Notifier interface {
 type Scheduler struct {
 	db   *db.DB
 	src  *sources.Manager
-	bot  *tgbotapi.BotAPI
+	out  *outbox.Outbox
 	notify Notifier
 
 	stopCh chan struct{}
 	wg     sync.WaitGroup
 
+	// isLeader reports whether this instance may post (single-instance lease).
+	isLeader func() bool
+
+	// chatLocks serializes posting per chat (scheduled posts vs. PostNow).
+	chatLocksMu sync.Mutex
+	chatLocks   map[int64]*sync.Mutex
+
 	// throttling notifications per chat
 	mu sync.Mutex
 	lastFailNotify map[int64]time.Time
+	// lastRecorded is the fetch time last written to price_history, per provider|method.
+	lastRecorded map[string]time.Time
 }
 
-func New(database *db.DB, src *sources.Manager, bot *tgbotapi.BotAPI, notifier Notifier) *Scheduler {
+func New(database *db.DB, src *sources.Manager, out *outbox.Outbox, notifier Notifier) *Scheduler {
 	return &Scheduler{
 		db: database,
 		src: src,
-		bot: bot,
+		out: out,
 		notify: notifier,
 		stopCh: make(chan struct{}),
+		chatLocks: map[int64]*sync.Mutex{},
 		lastFailNotify: map[int64]time.Time{},
+		lastRecorded: map[string]time.Time{},
 	}
 }
 
+// SetLeaderCheck makes ticks a no-op while fn reports false, so a standby
+// instance never posts.
+func (s *Scheduler) SetLeaderCheck(fn func() bool) {
+	s.isLeader = fn
+}
+
+// lockChat acquires the posting lock for chatID and returns its unlock func.
+func (s *Scheduler) lockChat(chatID int64) func() {
+	s.chatLocksMu.Lock()
+	l, ok := s.chatLocks[chatID]
+	if !ok {
+		l = &sync.Mutex{}
+		s.chatLocks[chatID] = l
+	}
+	s.chatLocksMu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
 func (s *Scheduler) Start() {
 	s.wg.Add(1)
 	go func() {
@@ -59,6 +101,8 @@ func (s *Scheduler) Stop() {
 }
 
 func (s *Scheduler) loop() {
+	// Catch up on anything that became due while we were down.
+	s.runTick()
 	for {
 		// Sleep until the next minute boundary in Tehran time.
 		now := utils.NowTehran()
@@ -74,7 +118,13 @@ func (s *Scheduler) loop() {
 	}
 }
 
+// runTick posts every chat whose next due time has passed. Due times live in
+// the DB, so a slow tick or a restart only delays a post: it is sent once on
+// the next tick instead of being skipped.
 func (s *Scheduler) runTick() {
+	if !s.leading() {
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Second)
 	defer cancel()
 
@@ -89,30 +139,37 @@ func (s *Scheduler) runTick() {
 
 	sem := make(chan struct{}, 5) // limit concurrency
 	var wg sync.WaitGroup
+	sourcesInUse := map[[2]string]bool{}
+	active := map[int64]db.ChatSettings{}
 
 	for _, c := range chats {
-		if !c.Approved || !c.Enabled {
+		// Chats with a known problem wait for a my_chat_member update (or an admin) to clear it.
+		if !c.Approved || !c.Enabled || c.Problem != "" {
 			continue
 		}
 		settings, err := s.db.GetChatSettings(ctx, c.ChatID)
 		if err != nil {
 			continue
 		}
-
-		// Downtime check
-		if settings.DowntimeEnabled {
-			start, ok1 := utils.ParseHHMM(settings.DowntimeStart)
-			end, ok2 := utils.ParseHHMM(settings.DowntimeEnd)
-			if ok1 && ok2 && utils.InDowntime(minuteOfDay, start, end) {
-				continue
-			}
-		}
+		sourcesInUse[[2]string{settings.SourceProvider, settings.SourceMethod}] = true
+		active[c.ChatID] = settings
 
 		interval := settings.IntervalMinutes
 		if interval <= 0 {
 			interval = 5
 		}
-		if minuteOfDay%interval != 0 {
+		due := utils.NextBoundary(now.Truncate(time.Minute).Add(-time.Minute), interval)
+		if settings.NextDueAt.Valid {
+			due = time.Unix(settings.NextDueAt.Int64, 0)
+		}
+		if now.Before(due) {
+			continue
+		}
+		next := utils.NextBoundary(now, interval)
+
+		// Downtime check (a post that fell due inside downtime is dropped, not caught up)
+		if inDowntime(settings, minuteOfDay) {
+			_ = s.db.SetNextDue(ctx, c.ChatID, next)
 			continue
 		}
 
@@ -121,10 +178,67 @@ func (s *Scheduler) runTick() {
 		go func(chatID int64, st db.ChatSettings) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			_ = s.postOnce(context.Background(), chatID, st, false)
+			// The lease may have moved while earlier posts ran; the new
+			// leader will pick this chat up.
+			if !s.leading() {
+				return
+			}
+			pctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+			defer cancel()
+			// Advance only once the post went out; a failed post is retried
+			// shortly instead of waiting a full interval.
+			due := next
+			if err := s.postOnce(pctx, chatID, st, false); err != nil {
+				if retry := now.Add(postRetryDelay); retry.Before(due) {
+					due = retry
+				}
+			}
+			if err := s.db.SetNextDue(context.Background(), chatID, due); err != nil {
+				log.Printf("[scheduler] chat %d: set next due: %v", chatID, err)
+			}
 		}(c.ChatID, settings)
 	}
 	wg.Wait()
+
+	if !s.leading() {
+		return
+	}
+	// The posts may have used up the tick's context; the follow-up jobs get their own.
+	jctx, jcancel := context.WithTimeout(context.Background(), tickJobsTimeout)
+	defer jcancel()
+	s.evaluateAlerts(jctx, active, minuteOfDay)
+	s.sendDigests(jctx, active, now)
+	s.runUserDeliveries(jctx, now)
+
+	if minuteOfDay%historySampleEvery == 0 {
+		s.sampleHistory(jctx, sourcesInUse)
+	}
+	if now.Minute() == 0 {
+		if err := s.db.PruneHistory(jctx, now.Add(-historyRetention)); err != nil {
+			log.Printf("[scheduler] prune history: %v", err)
+		}
+		if err := s.db.PruneMessages(jctx, now.Add(-48*time.Hour)); err != nil {
+			log.Printf("[scheduler] prune messages: %v", err)
+		}
+		s.expirePendingChats(jctx, now)
+	}
+	if err := jctx.Err(); err != nil {
+		log.Printf("[scheduler] tick jobs for %s did not finish: %v", now.Format("15:04"), err)
+	}
+}
+
+// leading reports whether this instance holds the posting lease.
+func (s *Scheduler) leading() bool {
+	return s.isLeader == nil || s.isLeader()
+}
+
+func inDowntime(settings db.ChatSettings, minuteOfDay int) bool {
+	if !settings.DowntimeEnabled {
+		return false
+	}
+	start, ok1 := utils.ParseHHMM(settings.DowntimeStart)
+	end, ok2 := utils.ParseHHMM(settings.DowntimeEnd)
+	return ok1 && ok2 && utils.InDowntime(minuteOfDay, start, end)
 }
 
 func (s *Scheduler) PostNow(chatID int64) {
@@ -140,6 +254,13 @@ func (s *Scheduler) PostNow(chatID int64) {
 
 // postOnce fetches, decides trigger gating, then posts/edits.
 func (s *Scheduler) postOnce(ctx context.Context, chatID int64, settings db.ChatSettings, forced bool) error {
+	unlock := s.lockChat(chatID)
+	defer unlock()
+
+	// Re-read under the lock: a concurrent post may have replaced the last message.
+	if fresh, err := s.db.GetChatSettings(ctx, chatID); err == nil {
+		settings = fresh
+	}
 	enabledIDs, err := s.db.EnabledItemIDs(ctx, chatID)
 	if err != nil {
 		return err
@@ -158,43 +279,114 @@ func (s *Scheduler) postOnce(ctx context.Context, chatID int64, settings db.Chat
 		return err
 	}
 
-	// Last values (for arrows/triggers)
-	lastVals, err := s.db.GetLastValues(ctx, chatID, enabledIDs)
+	s.recordSnapshot(ctx, snap)
+
+	// Baseline values (for arrows/triggers)
+	lastVals, err := Baseline(ctx, s.db, settings, enabledIDs)
 	if err != nil {
 		return err
 	}
 
 	out := render.BuildMessage(ctx, settings, tmpl, enabledIDs, snap, lastVals)
 
-	// Trigger gating (unless forced)
+	// Trigger gating (unless forced). A pinned board is edited on every tick;
+	// triggers only decide whether a separate update message goes out.
+	pinned := settings.PostMode == "pin"
+	update := false
 	if !forced && len(settings.TriggerItems) > 0 {
-		if !s.anyTriggerChanged(settings, out.UsedValues, lastVals) {
+		fire := s.shouldPostOnTrigger(settings, out.UsedValues, lastVals)
+		if !fire && !pinned {
 			// Still update fetch health
 			_ = s.db.UpdateFetchHealth(ctx, chatID, snap.FetchedAt, "")
 			return nil
 		}
+		update = fire && pinned
+	}
+
+	// Edit modes: nothing visible changed, so skip the Telegram call.
+	hash := out.Hash
+	if settings.EditIgnoreTime {
+		hash = out.TimelessHash
+	}
+	unchanged := !forced && (settings.PostMode == "" || settings.PostMode == "edit" || pinned) &&
+		settings.LastPostMessageID.Valid && settings.LastPostHash.Valid && settings.LastPostHash.String == hash
+	if unchanged && !update {
+		_ = s.db.UpdateFetchHealth(ctx, chatID, snap.FetchedAt, "")
+		return nil
 	}
 
 	// Post or edit
-	msgID, err := s.postOrEdit(ctx, chatID, settings, out)
+	warn := ""
+	if !unchanged {
+		msgID, w, err := s.postOrEdit(ctx, chatID, settings, &out)
+		if err != nil {
+			_ = s.db.UpdateFetchHealth(ctx, chatID, snap.FetchedAt, err.Error())
+			if !s.handleChatGone(ctx, chatID, err) {
+				s.notifySourceFail(ctx, chatID, settings, err)
+			}
+			return err
+		}
+		warn = w
+		_ = s.db.UpdateLastPost(ctx, chatID, msgID, boardMedia(out), time.Now())
+		_ = s.db.SetLastPostHash(ctx, chatID, hash)
+	}
+	if update {
+		if err := s.sendTriggerUpdate(ctx, chatID, out); err != nil {
+			warn = "pin: trigger update: " + err.Error()
+		}
+	}
+
+	// Save last values for arrows/triggers. With triggers, a pinned board's
+	// baseline is the last trigger update, not the last edit.
+	if !pinned || update || len(settings.TriggerItems) == 0 {
+		for id, v := range out.UsedValues {
+			_ = s.db.SetLastValue(ctx, chatID, id, v)
+		}
+	}
+	_ = s.db.UpdateFetchHealth(ctx, chatID, snap.FetchedAt, warn)
+	return nil
+}
+
+// sendTriggerUpdate posts the board as a separate, unpinned message next to
+// a pinned board when a trigger fires.
+func (s *Scheduler) sendTriggerUpdate(ctx context.Context, chatID int64, out render.Output) error {
+	ids, err := s.sendBoard(ctx, chatID, out)
 	if err != nil {
-		_ = s.db.UpdateFetchHealth(ctx, chatID, snap.FetchedAt, err.Error())
-		s.notifySourceFail(ctx, chatID, settings, err)
 		return err
 	}
+	if len(ids) > 1 {
+		_ = s.db.TrackAlbum(ctx, chatID, ids, "update")
+	} else {
+		_ = s.db.TrackMessage(ctx, chatID, ids[0], "update")
+	}
+	return s.db.SetLastTriggerTime(ctx, chatID, time.Now())
+}
 
-	// Save last values for arrows/triggers
-	for id, v := range out.UsedValues {
-		_ = s.db.SetLastValue(ctx, chatID, id, v)
+// shouldPostOnTrigger applies trigger thresholds plus the chat's cooldown
+// (minimum gap between posts) and max silence (post anyway after that long).
+func (s *Scheduler) shouldPostOnTrigger(settings db.ChatSettings, current map[string]float64, last map[string]float64) bool {
+	lastAt := settings.LastPostTime
+	if settings.PostMode == "pin" {
+		// The pinned board is edited every tick; count from the last update message.
+		lastAt = settings.LastTriggerTime
 	}
-	_ = s.db.UpdateLastPost(ctx, chatID, msgID, time.Now())
-	_ = s.db.UpdateFetchHealth(ctx, chatID, snap.FetchedAt, "")
-	return nil
+	sinceLast := time.Duration(1<<63 - 1)
+	if lastAt.Valid {
+		sinceLast = time.Since(time.Unix(lastAt.Int64, 0))
+	}
+	if silence := settings.TriggerMaxSilenceMinutes; silence > 0 && sinceLast >= time.Duration(silence)*time.Minute {
+		return true
+	}
+	if !s.anyTriggerChanged(settings, current, last) {
+		return false
+	}
+	if cd := TriggerCooldown(settings); cd > 0 && sinceLast < time.Duration(cd)*time.Minute {
+		return false
+	}
+	return true
 }
 
 func (s *Scheduler) anyTriggerChanged(settings db.ChatSettings, current map[string]float64, last map[string]float64) bool {
-	thresholdType := settings.TriggerThresholdType
-	thresholdVal := settings.TriggerThresholdValue
 	for _, id := range settings.TriggerItems {
 		cur, ok := current[id]
 		if !ok {
@@ -208,17 +400,18 @@ func (s *Scheduler) anyTriggerChanged(settings db.ChatSettings, current map[stri
 		if delta < 0 {
 			delta = -delta
 		}
-		switch thresholdType {
+		th := settings.ThresholdFor(id)
+		switch th.Type {
 		case "pct":
 			if prev == 0 {
 				return true
 			}
 			pct := (delta / prev) * 100.0
-			if pct >= thresholdVal {
+			if pct >= th.Value {
 				return true
 			}
 		default: // abs
-			if delta >= thresholdVal {
+			if delta >= th.Value {
 				return true
 			}
 		}
@@ -226,67 +419,229 @@ func (s *Scheduler) anyTriggerChanged(settings db.ChatSettings, current map[stri
 	return false
 }
 
-func (s *Scheduler) postOrEdit(ctx context.Context, chatID int64, settings db.ChatSettings, out render.Output) (int, error) {
+// postOrEdit publishes out according to the chat's post mode and returns the
+// board message ID plus a non-fatal warning for the status panel (e.g. the
+// bot lost the right to delete or pin messages).
+//
+//   - new:    always a new message
+//   - edit:   edit the last board, new message if that fails
+//   - repost: delete the previous board(s), then post a new one
+//   - pin:    edit the pinned board; a new board is pinned
+//
+// A rotating template moves on to its next image with each new board.
+func (s *Scheduler) postOrEdit(ctx context.Context, chatID int64, settings db.ChatSettings, out *render.Output) (int, string, error) {
 	postMode := settings.PostMode
 	if postMode == "" {
 		postMode = "edit"
 	}
+	warn := ""
 
-	// If edit, try to edit last message
-	if postMode == "edit" {
-		mid, err := s.db.GetLastPostMessageID(ctx, chatID)
-		if err == nil && mid.Valid {
-			if out.MediaType != "" && out.MediaFileID != "" {
-				// Media message: edit caption
-				edit := tgbotapi.NewEditMessageCaption(chatID, int(mid.Int64), out.Text)
-				_, err := s.bot.Request(edit)
-				if err == nil {
-					return int(mid.Int64), nil
-				}
+	mid, err := s.db.GetLastPostMessageID(ctx, chatID)
+	hasLast := err == nil && mid.Valid
+
+	switch postMode {
+	case "edit", "pin":
+		if !hasLast {
+			break
+		}
+		last := int(mid.Int64)
+		if settings.LastPostMedia.Valid && settings.LastPostMedia.String != boardMedia(*out) {
+			// The template's media changed: a text message can't gain a photo
+			// (nor a photo become a video) by editing, so re-anchor.
+			s.dropBoard(ctx, chatID, last)
+			break
+		}
+		err := s.editBoard(ctx, chatID, last, *out)
+		switch classifyEdit(err) {
+		case editOK, editNotModified:
+			return last, "", nil
+		case editGone:
+			_ = s.db.ForgetMessage(ctx, chatID, last)
+		case editTypeMismatch:
+			s.dropBoard(ctx, chatID, last)
+		default:
+			log.Printf("[scheduler] chat %d: edit board: %v", chatID, err)
+		}
+		// Fall through to a new post.
+	case "repost":
+		warn = s.deleteBoards(ctx, chatID)
+	}
+
+	if postMode == "pin" && hasLast {
+		// Re-anchoring: the old board may still be pinned (e.g. it couldn't be deleted).
+		unpin := tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: int(mid.Int64)}
+		if _, err := s.out.Request(ctx, chatID, unpin); err != nil {
+			log.Printf("[scheduler] chat %d: unpin old board: %v", chatID, err)
+		}
+	}
+	if out.Rotating && len(out.Media) > 0 && hasLast {
+		n := len(out.Media)
+		next := ((settings.MediaIndex+1)%n + n) % n
+		out.RotateTo(next)
+		_ = s.db.SetMediaIndex(ctx, chatID, next)
+	}
+	ids, err := s.sendBoard(ctx, chatID, *out)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(ids) > 1 {
+		_ = s.db.TrackAlbum(ctx, chatID, ids, "board")
+	} else {
+		_ = s.db.TrackMessage(ctx, chatID, ids[0], "board")
+	}
+	msgID := ids[0]
+
+	if postMode == "pin" {
+		pin := tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: msgID, DisableNotification: true}
+		if _, err := s.out.Request(ctx, chatID, pin); err != nil {
+			if isRightsError(err) {
+				warn = "pin: bot can't pin messages (needs the 'Pin messages' admin right): " + err.Error()
 			} else {
-				edit := tgbotapi.NewEditMessageText(chatID, int(mid.Int64), out.Text)
-				edit.DisableWebPagePreview = true
-				_, err := s.bot.Request(edit)
-				if err == nil {
-					return int(mid.Int64), nil
-				}
+				warn = "pin: " + err.Error()
 			}
-			// If edit failed, fall through to new post.
 		}
 	}
+	return msgID, warn, nil
+}
+
+// boardMedia identifies the media of a rendered board, as stored in last_post_media.
+func boardMedia(out render.Output) string {
+	if out.MediaType == "" || out.MediaFileID == "" {
+		return ""
+	}
+	return out.MediaType + ":" + out.MediaFileID
+}
 
-	// Send new
+// dropBoard deletes a board that is being replaced (best effort) and stops
+// tracking it, with the rest of its album if it was one.
+func (s *Scheduler) dropBoard(ctx context.Context, chatID int64, msgID int) {
+	ids, err := s.db.AlbumMessages(ctx, chatID, msgID)
+	if err != nil {
+		ids = []int{msgID}
+	}
+	for _, id := range ids {
+		if _, err := s.out.Request(ctx, chatID, tgbotapi.NewDeleteMessage(chatID, id)); err != nil && !isDeleteGone(err) {
+			log.Printf("[scheduler] chat %d: delete replaced board %d: %v", chatID, id, err)
+			continue
+		}
+		_ = s.db.ForgetMessage(ctx, chatID, id)
+	}
+}
+
+func (s *Scheduler) editBoard(ctx context.Context, chatID int64, msgID int, out render.Output) error {
+	if out.MediaType != "" && out.MediaFileID != "" {
+		// Media message: edit caption
+		edit := tgbotapi.NewEditMessageCaption(chatID, msgID, out.Text)
+		_, err := s.out.Request(ctx, chatID, edit)
+		return err
+	}
+	edit := tgbotapi.NewEditMessageText(chatID, msgID, out.Text)
+	edit.DisableWebPagePreview = true
+	_, err := s.out.Request(ctx, chatID, edit)
+	return err
+}
+
+// deleteBoards deletes every tracked board message of chatID. Messages that
+// are already gone are forgotten; a warning is returned if the bot lacks the
+// right to delete.
+func (s *Scheduler) deleteBoards(ctx context.Context, chatID int64) string {
+	msgs, err := s.db.ListMessages(ctx, chatID, "board")
+	if err != nil {
+		return ""
+	}
+	warn := ""
+	for _, m := range msgs {
+		_, err := s.out.Request(ctx, chatID, tgbotapi.NewDeleteMessage(chatID, m.MessageID))
+		switch {
+		case err == nil, isDeleteGone(err):
+			_ = s.db.ForgetMessage(ctx, chatID, m.MessageID)
+		case isRightsError(err):
+			warn = "repost: bot can't delete the previous post (needs the 'Delete messages' admin right): " + err.Error()
+		default:
+			warn = "repost: delete previous post: " + err.Error()
+		}
+	}
+	return warn
+}
+
+// BoardMessage builds the Telegram message for a rendered board: plain
+// text, a single media file with the board as its caption, or a media
+// group with the caption on its first file.
+func BoardMessage(chatID int64, out render.Output) tgbotapi.Chattable {
+	if out.MediaType == db.MediaAlbum {
+		files := make([]interface{}, 0, len(out.Media))
+		for i, m := range out.Media {
+			caption := ""
+			if i == 0 {
+				caption = out.Text
+			}
+			switch m.Type {
+			case db.MediaVideo:
+				v := tgbotapi.NewInputMediaVideo(tgbotapi.FileID(m.FileID))
+				v.Caption = caption
+				files = append(files, v)
+			case db.MediaDocument:
+				d := tgbotapi.NewInputMediaDocument(tgbotapi.FileID(m.FileID))
+				d.Caption = caption
+				files = append(files, d)
+			default:
+				p := tgbotapi.NewInputMediaPhoto(tgbotapi.FileID(m.FileID))
+				p.Caption = caption
+				files = append(files, p)
+			}
+		}
+		return tgbotapi.NewMediaGroup(chatID, files)
+	}
 	if out.MediaType != "" && out.MediaFileID != "" {
+		file := tgbotapi.FileID(out.MediaFileID)
 		switch out.MediaType {
-		case "video":
-			msg := tgbotapi.NewVideo(chatID, tgbotapi.FileID(out.MediaFileID))
+		case db.MediaVideo:
+			msg := tgbotapi.NewVideo(chatID, file)
 			msg.Caption = out.Text
-			msg.ParseMode = ""
-			sent, err := s.bot.Send(msg)
-			if err != nil {
-				return 0, err
-			}
-			return sent.MessageID, nil
+			return msg
+		case db.MediaAnimation:
+			msg := tgbotapi.NewAnimation(chatID, file)
+			msg.Caption = out.Text
+			return msg
+		case db.MediaDocument:
+			msg := tgbotapi.NewDocument(chatID, file)
+			msg.Caption = out.Text
+			return msg
 		default: // photo
-			msg := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(out.MediaFileID))
+			msg := tgbotapi.NewPhoto(chatID, file)
 			msg.Caption = out.Text
-			msg.ParseMode = ""
-			sent, err := s.bot.Send(msg)
-			if err != nil {
-				return 0, err
-			}
-			return sent.MessageID, nil
+			return msg
 		}
 	}
-
 	msg := tgbotapi.NewMessage(chatID, out.Text)
 	msg.DisableWebPagePreview = true
-	msg.ParseMode = ""
-	sent, err := s.bot.Send(msg)
+	return msg
+}
+
+// sendBoard posts a board and returns its message IDs; an album has one
+// message per file, the first carrying the caption.
+func (s *Scheduler) sendBoard(ctx context.Context, chatID int64, out render.Output) ([]int, error) {
+	msg := BoardMessage(chatID, out)
+	if out.MediaType != db.MediaAlbum {
+		sent, err := s.out.Send(ctx, chatID, msg)
+		if err != nil {
+			return nil, err
+		}
+		return []int{sent.MessageID}, nil
+	}
+	resp, err := s.out.Request(ctx, chatID, msg)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	var sent []tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &sent); err != nil || len(sent) == 0 {
+		return nil, fmt.Errorf("send album: unexpected response: %v", err)
+	}
+	ids := make([]int, 0, len(sent))
+	for _, m := range sent {
+		ids = append(ids, m.MessageID)
 	}
-	return sent.MessageID, nil
+	return ids, nil
 }
 
 func (s *Scheduler) notifySourceFail(ctx context.Context, chatID int64, settings db.ChatSettings, err error) {
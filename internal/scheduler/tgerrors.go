@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+)
+
+// tgDescription returns the lower-cased Telegram error description of err,
+// or "" if err is not a Telegram API error.
+func tgDescription(err error) string {
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) {
+		return strings.ToLower(tgErr.Message)
+	}
+	return ""
+}
+
+type editResult int
+
+const (
+	editOK editResult = iota
+	editNotModified
+	editGone         // deleted, unreachable or no longer editable
+	editTypeMismatch // text vs caption: the message's media doesn't match
+	editFailed
+)
+
+// classifyEdit sorts the error of an edit call into the cases postOrEdit
+// handles differently.
+func classifyEdit(err error) editResult {
+	if err == nil {
+		return editOK
+	}
+	d := tgDescription(err)
+	switch {
+	case strings.Contains(d, "message is not modified"):
+		return editNotModified
+	case strings.Contains(d, "message to edit not found"),
+		strings.Contains(d, "message_id_invalid"),
+		strings.Contains(d, "message can't be edited"):
+		return editGone
+	case strings.Contains(d, "no text in the message"),
+		strings.Contains(d, "no caption in the message"),
+		strings.Contains(d, "message has no text"),
+		strings.Contains(d, "message has no caption"):
+		return editTypeMismatch
+	}
+	return editFailed
+}
+
+// isRightsError reports whether Telegram refused because the bot lacks an
+// admin right in the chat (delete, pin, ...).
+func isRightsError(err error) bool {
+	d := tgDescription(err)
+	return strings.Contains(d, "not enough rights") ||
+		strings.Contains(d, "chat_admin_required") ||
+		strings.Contains(d, "have no rights") ||
+		strings.Contains(d, "need administrator rights")
+}
+
+// isDeleteGone reports whether a message can no longer be deleted because
+// it is already gone or too old for bots to delete (48h).
+func isDeleteGone(err error) bool {
+	d := tgDescription(err)
+	return strings.Contains(d, "message to delete not found") ||
+		strings.Contains(d, "message can't be deleted")
+}
+
+// migratedTo returns the supergroup ID a group was upgraded to if err says
+// so, or 0.
+func migratedTo(err error) int64 {
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) {
+		return tgErr.ResponseParameters.MigrateToChatID
+	}
+	return 0
+}
+
+// sendProblem maps a failed send to the chat problem it reveals, or "".
+func sendProblem(err error) string {
+	d := tgDescription(err)
+	switch {
+	case strings.Contains(d, "bot was kicked"):
+		return db.ProblemKicked
+	case strings.Contains(d, "bot is not a member"),
+		strings.Contains(d, "chat not found"):
+		return db.ProblemLeft
+	case strings.Contains(d, "have no rights to send"),
+		strings.Contains(d, "not enough rights to send"):
+		return db.ProblemRestricted
+	case strings.Contains(d, "need administrator rights"),
+		strings.Contains(d, "chat_write_forbidden"):
+		return db.ProblemNoRights
+	}
+	return ""
+}
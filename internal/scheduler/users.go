@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Armin-kho/persian-currency-bot/internal/db"
+	"github.com/Armin-kho/persian-currency-bot/internal/items"
+	"github.com/Armin-kho/persian-currency-bot/internal/render"
+	"github.com/Armin-kho/persian-currency-bot/internal/sources"
+	"github.com/Armin-kho/persian-currency-bot/internal/utils"
+)
+
+// runUserDeliveries sends personal daily boards and evaluates personal
+// alerts, both priced from the global default source.
+func (s *Scheduler) runUserDeliveries(ctx context.Context, now time.Time) {
+	subs, err := s.db.ListEnabledUserSubscriptions(ctx)
+	if err != nil {
+		log.Printf("[scheduler] list user subscriptions: %v", err)
+		return
+	}
+	alerts, err := s.db.ListEnabledUserAlerts(ctx)
+	if err != nil {
+		log.Printf("[scheduler] list user alerts: %v", err)
+		return
+	}
+	if len(subs) == 0 && len(alerts) == 0 {
+		return
+	}
+
+	provider, method := s.db.DefaultSource(ctx)
+	snap, err := s.src.Get(ctx, sources.Provider(provider), sources.Method(method))
+	if err != nil {
+		return
+	}
+	s.recordSnapshot(ctx, snap)
+	st := db.ChatSettings{SourceProvider: provider, SourceMethod: method, PriceMode: "both", Digits: "en"}
+
+	minuteOfDay := now.Hour()*60 + now.Minute()
+	today := utils.JalaliDate(now)
+	for _, sub := range subs {
+		at, ok := utils.ParseHHMM(sub.AtTime)
+		if !ok || minuteOfDay < at || sub.LastSentKey == today {
+			continue
+		}
+		text := render.QuoteList(ctx, st, sub.Items, snap, nil)
+		if text == "" {
+			continue
+		}
+		msg := tgbotapi.NewMessage(sub.UserID, "📬 برد روزانه شما\n\n"+text)
+		msg.DisableWebPagePreview = true
+		if _, err := s.out.Send(ctx, sub.UserID, msg); err != nil {
+			s.userSendFailed(ctx, sub.UserID, err)
+			continue
+		}
+		_ = s.db.UpdateUserSubscription(ctx, sub.UserID, "last_sent_key", today)
+	}
+
+	for _, a := range alerts {
+		q, ok := snap.Quotes[a.ItemID]
+		if !ok {
+			continue
+		}
+		cur, ok := render.UsedValue("sell", q)
+		if !ok {
+			continue
+		}
+		if (a.Kind == "above" && cur < a.Level) || (a.Kind == "below" && cur > a.Level) {
+			continue
+		}
+		it, _ := items.ByID(a.ItemID)
+		dir := "بالای"
+		if a.Kind == "below" {
+			dir = "زیر"
+		}
+		text := fmt.Sprintf("🔔 %s %s به %s رسید\n(هشدار شما: %s %s)\n🕒 %s",
+			it.Emoji, it.NameFa, utils.FormatNumber(cur, q.Unit, "en"), dir, utils.FormatNumber(a.Level, q.Unit, "en"),
+			utils.JalaliDateTime(now))
+		if _, err := s.out.Send(ctx, a.UserID, tgbotapi.NewMessage(a.UserID, text)); err != nil {
+			s.userSendFailed(ctx, a.UserID, err)
+			continue
+		}
+		// One-shot: the user sets a new alert if they want another.
+		_ = s.db.DisableUserAlert(ctx, a.AlertID)
+	}
+}
+
+// userSendFailed stops deliveries to users who blocked the bot.
+func (s *Scheduler) userSendFailed(ctx context.Context, userID int64, err error) {
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.Code == 403 {
+		log.Printf("[scheduler] user %d blocked the bot; disabling personal deliveries", userID)
+		_ = s.db.DisableUserDelivery(ctx, userID)
+	}
+}
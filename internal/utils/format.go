@@ -4,6 +4,7 @@ package utils
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -119,3 +120,39 @@ func formatFloatWithCommas(f float64, decimals int) string {
 	}
 	return b.String()
 }
+
+// NormalizeDigits converts Persian/Arabic-Indic digits and separators to ASCII.
+func NormalizeDigits(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r >= '۰' && r <= '۹':
+			b.WriteRune('0' + (r - '۰'))
+		case r >= '٠' && r <= '٩':
+			b.WriteRune('0' + (r - '٠'))
+		case r == '٫':
+			b.WriteRune('.')
+		case r == '٬' || r == '،':
+			b.WriteRune(',')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ParseNumber parses user input like "70,000", "۷۰٬۰۰۰" or "1.5".
+func ParseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(NormalizeDigits(s))
+	s = strings.ReplaceAll(s, ",", "")
+	s = strings.ReplaceAll(s, "_", "")
+	if s == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
@@ -52,6 +52,22 @@ func FormatHHMM(minutes int) string {
 	return time.Date(2000, 1, 1, h, m, 0, 0, TehranLoc()).Format("15:04")
 }
 
+// NextBoundary returns the first interval boundary (aligned to Tehran
+// midnight, e.g. 10:00, 10:05, ... for 5 minutes) strictly after t.
+func NextBoundary(t time.Time, intervalMinutes int) time.Time {
+	if intervalMinutes <= 0 {
+		intervalMinutes = 1
+	}
+	t = t.In(TehranLoc())
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	minuteOfDay := t.Hour()*60 + t.Minute()
+	next := (minuteOfDay/intervalMinutes + 1) * intervalMinutes
+	if next >= 1440 {
+		return midnight.AddDate(0, 0, 1)
+	}
+	return midnight.Add(time.Duration(next) * time.Minute)
+}
+
 // InDowntime checks if a given minute-of-day is within the downtime interval.
 // If start == end, it means "no downtime" (or full day depending on enabled flag).
 // Supports ranges that cross midnight, e.g. 20:00 -> 10:00.
@@ -65,3 +81,36 @@ func InDowntime(minuteOfDay int, start int, end int) bool {
 	// Crosses midnight
 	return minuteOfDay >= start || minuteOfDay < end
 }
+
+// JalaliDate returns a string like "1404/10/09" (in Tehran time).
+func JalaliDate(t time.Time) string {
+	return jalaali.New(t.In(TehranLoc())).Format("2006/01/02")
+}
+
+// PeriodStart returns the beginning of the Tehran day, Jalali week
+// (Saturday) or Jalali month containing t. period is "daily", "weekly" or "monthly".
+func PeriodStart(t time.Time, period string) time.Time {
+	j := jalaali.New(t.In(TehranLoc()))
+	switch period {
+	case "weekly":
+		return j.BeginningOfWeek().Time()
+	case "monthly":
+		return j.BeginningOfMonth().Time()
+	default:
+		return j.BeginningOfDay().Time()
+	}
+}
+
+// IsPeriodEnd reports whether t is on the last day of its period: any day
+// for "daily", Friday for "weekly", the last day of the Jalali month for "monthly".
+func IsPeriodEnd(t time.Time, period string) bool {
+	j := jalaali.New(t.In(TehranLoc()))
+	switch period {
+	case "weekly":
+		return j.Weekday() == jalaali.Jomeh
+	case "monthly":
+		return j.Day() == j.LastMonthDay().Day()
+	default:
+		return true
+	}
+}